@@ -0,0 +1,135 @@
+package main
+
+import "sync/atomic"
+
+// AdmissionPolicy decides whether a new item may evict a live one when the
+// cache is full.
+type AdmissionPolicy int
+
+const (
+	// AdmitAll always admits new items. It is the default policy.
+	AdmitAll AdmissionPolicy = iota
+	// TinyLFU admits a new item only if it has been used more often
+	// recently than the item it would evict, so a burst of keys seen once
+	// cannot flush out hot ones. Frequencies are estimated with a small
+	// count-min sketch that is halved periodically, so old popularity fades.
+	TinyLFU
+)
+
+// WithAdmissionPolicy sets the policy that decides whether a write that has
+// to evict an item is admitted. A rejected Set is dropped and TrySet reports
+// ErrCapacity.
+func WithAdmissionPolicy(p AdmissionPolicy) Option {
+	return func(c *Cache) {
+		c.admission = p
+	}
+}
+
+const (
+	sketchDepth    = 4
+	minSketchWidth = 1024
+	// sketchSampleFactor is how many increments per counter column the
+	// sketch takes before all counters are halved.
+	sketchSampleFactor = 10
+)
+
+// countMinSketch estimates how often keys were used. Counters are updated
+// atomically, so it may be used with only the read lock held.
+type countMinSketch struct {
+	rows      [sketchDepth][]uint32
+	mask      uint64
+	additions uint64
+	resetAt   uint64
+}
+
+// newCountMinSketch creates a sketch with at least width counters per row.
+func newCountMinSketch(width int) *countMinSketch {
+	w := minSketchWidth
+	for w < width {
+		w <<= 1
+	}
+	s := &countMinSketch{mask: uint64(w - 1), resetAt: uint64(w * sketchSampleFactor)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint32, w)
+	}
+	return s
+}
+
+// index returns the column of k in row i, using double hashing to derive one
+// index per row from a single hash.
+func (s *countMinSketch) index(h uint64, i int) uint64 {
+	lo, hi := h&0xffffffff, h>>32
+	return (lo + uint64(i)*hi) & s.mask
+}
+
+// increment records a use of k.
+func (s *countMinSketch) increment(k string) {
+	h := fnv64a(k)
+	for i := range s.rows {
+		atomic.AddUint32(&s.rows[i][s.index(h, i)], 1)
+	}
+	if atomic.AddUint64(&s.additions, 1)%s.resetAt == 0 {
+		s.halve()
+	}
+}
+
+// estimate returns an upper bound on how often k was used.
+func (s *countMinSketch) estimate(k string) uint32 {
+	h := fnv64a(k)
+	min := ^uint32(0)
+	for i := range s.rows {
+		if n := atomic.LoadUint32(&s.rows[i][s.index(h, i)]); n < min {
+			min = n
+		}
+	}
+	return min
+}
+
+// generation returns how many times the sketch has been halved.
+func (s *countMinSketch) generation() uint64 {
+	return atomic.LoadUint64(&s.additions) / s.resetAt
+}
+
+// halve ages the sketch by halving every counter.
+func (s *countMinSketch) halve() {
+	for i := range s.rows {
+		row := s.rows[i]
+		for j := range row {
+			for {
+				n := atomic.LoadUint32(&row[j])
+				if atomic.CompareAndSwapUint32(&row[j], n, n/2) {
+					break
+				}
+			}
+		}
+	}
+}
+
+// admitLocked records a write of k and reports whether it may replace the
+// item the eviction policy would remove next. The caller must hold the
+// write lock.
+func (c *Cache) admitLocked(k string) bool {
+	if c.sketch == nil {
+		return true
+	}
+	c.sketch.increment(k)
+	victim := c.victimLocked()
+	return victim == nil || c.sketch.estimate(k) > c.sketch.estimate(victim.key)
+}
+
+// victimLocked returns the live item evictLocked would remove first. The
+// caller must hold the write lock.
+func (c *Cache) victimLocked() *item {
+	switch c.policy {
+	case EvictLRU, EvictSegmentedLRU:
+		return c.order.back()
+	case EvictLFU:
+		return c.leastFrequentLocked()
+	case EvictLowestCost:
+		return c.cheapestLocked()
+	}
+	for _, it := range c.items {
+		return it
+	}
+	return nil
+}
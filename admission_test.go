@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// scan fills c with hot, reads it often, then floods c with keys that are
+// written once and never read again.
+func scan(c *Cache) {
+	c.Set("hot", "1", time.Hour)
+	for i := 0; i < 20; i++ {
+		c.Get("hot")
+	}
+	for i := 0; i < 1000; i++ {
+		c.Set("cold"+strconv.Itoa(i), "x", time.Hour)
+	}
+}
+
+func TestTinyLFUResistsScan(t *testing.T) {
+	plain := New(WithMaxItems(10), WithEvictionPolicy(EvictLRU))
+	scan(plain)
+	if plain.Has("hot") {
+		t.Fatal("hot key survived the scan without admission; the test workload is too weak")
+	}
+
+	c := New(WithMaxItems(10), WithEvictionPolicy(EvictLRU), WithAdmissionPolicy(TinyLFU))
+	scan(c)
+	if !c.Has("hot") {
+		t.Fatal("hot key was evicted by a scan of cold keys")
+	}
+	if n := c.Size(); n != 10 {
+		t.Fatalf("Size = %d, want 10", n)
+	}
+}
+
+func TestTinyLFURejection(t *testing.T) {
+	c := New(WithMaxItems(1), WithAdmissionPolicy(TinyLFU))
+	c.Set("a", "1", time.Hour)
+	c.Get("a")
+
+	if err := c.TrySet("b", "2", time.Hour); !errors.Is(err, ErrCapacity) {
+		t.Fatalf("TrySet of a colder key: err = %v, want ErrCapacity", err)
+	}
+	// Updating a resident key never needs admission.
+	if err := c.TrySet("a", "3", time.Hour); err != nil {
+		t.Fatalf("TrySet of a resident key: %v", err)
+	}
+
+	// Once b has been asked for more often than a, it gets in.
+	for i := 0; i < 5; i++ {
+		c.Get("b")
+	}
+	if err := c.TrySet("b", "2", time.Hour); err != nil {
+		t.Fatalf("TrySet of a hotter key: %v", err)
+	}
+	if c.Has("a") {
+		t.Fatal("a was not evicted for the hotter key b")
+	}
+}
+
+func TestSketchHalves(t *testing.T) {
+	s := newCountMinSketch(0)
+	for i := 0; i < 8; i++ {
+		s.increment("k")
+	}
+	s.halve()
+	if n := s.estimate("k"); n != 4 {
+		t.Fatalf("estimate after halving = %d, want 4", n)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// bloomHashes is the number of counters each key sets in the Bloom filter.
+const bloomHashes = 7
+
+// WithBloomFilter keeps a Bloom filter of the stored keys, sized for about
+// expectedItems of them, so that Get, GetWithExpiry and Lookup can report most misses
+// without taking the lock or touching the map. A key the filter may hold
+// falls through to the normal lookup, so results are never wrong, only
+// slower once the cache holds many more keys than expected. The filter
+// counts rather than sets bits so that deletions are taken out of it, and
+// is rebuilt empty by Flush. It costs about 40 bytes per expected item.
+func WithBloomFilter(expectedItems int) Option {
+	return func(c *Cache) {
+		c.bloom.Store(newBloomFilter(expectedItems))
+	}
+}
+
+// bloomFilter is a counting Bloom filter. Counters are only changed under
+// the cache's write lock but are read without any lock.
+type bloomFilter struct {
+	expected int
+	counters []uint32
+}
+
+func newBloomFilter(expectedItems int) *bloomFilter {
+	if expectedItems < 1 {
+		expectedItems = 1
+	}
+	// About 1% false positives with bloomHashes hashes.
+	m := int(math.Ceil(float64(expectedItems) * 9.6))
+	return &bloomFilter{expected: expectedItems, counters: make([]uint32, m)}
+}
+
+// indexes calls f with the counter index of each of k's hashes, derived
+// from two halves of its FNV-1a hash.
+func (b *bloomFilter) indexes(k string, f func(i uint32)) {
+	h := fnv64a(k)
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	m := uint32(len(b.counters))
+	for i := uint32(0); i < bloomHashes; i++ {
+		f((h1 + i*h2) % m)
+	}
+}
+
+func (b *bloomFilter) add(k string) {
+	b.indexes(k, func(i uint32) { atomic.AddUint32(&b.counters[i], 1) })
+}
+
+func (b *bloomFilter) remove(k string) {
+	b.indexes(k, func(i uint32) { atomic.AddUint32(&b.counters[i], ^uint32(0)) })
+}
+
+// mayContain reports false only if k is certainly not in the filter.
+func (b *bloomFilter) mayContain(k string) bool {
+	ok := true
+	b.indexes(k, func(i uint32) {
+		if atomic.LoadUint32(&b.counters[i]) == 0 {
+			ok = false
+		}
+	})
+	return ok
+}
+
+// bloomFilter returns the cache's Bloom filter, or nil if it has none.
+func (c *Cache) bloomFilter() *bloomFilter {
+	b, _ := c.bloom.Load().(*bloomFilter)
+	return b
+}
+
+// resetBloomLocked replaces the Bloom filter, if there is one, with an empty
+// one of the same size. The caller must hold the write lock.
+func (c *Cache) resetBloomLocked() {
+	if b := c.bloomFilter(); b != nil {
+		c.bloom.Store(newBloomFilter(b.expected))
+	}
+}
+
+// bloomMiss reports whether the Bloom filter rules k out, counting the miss
+// as a lookup under the lock would.
+func (c *Cache) bloomMiss(k string) bool {
+	b := c.bloomFilter()
+	if b == nil || b.mayContain(k) {
+		return false
+	}
+	if c.sketch != nil {
+		c.sketch.increment(k)
+	}
+	if c.hot != nil {
+		c.hot.record(k)
+	}
+	atomic.AddUint64(&c.misses, 1)
+	return true
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBloomFilterSkipsLock(t *testing.T) {
+	c := New(WithBloomFilter(1000))
+	for i := 0; i < 100; i++ {
+		c.Set(strconv.Itoa(i), "v", time.Hour)
+	}
+
+	// With the write lock held, only a lookup that never reaches the map
+	// can finish.
+	c.mu.Lock()
+	done := make(chan int)
+	go func() {
+		rejected := 0
+		for i := 0; i < 100; i++ {
+			if c.bloomMiss("absent:" + strconv.Itoa(i)) {
+				rejected++
+			}
+		}
+		done <- rejected
+	}()
+	var rejected int
+	select {
+	case rejected = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lookups of absent keys waited for the lock")
+	}
+	c.mu.Unlock()
+	if rejected < 90 {
+		t.Fatalf("the filter rejected %d of 100 absent keys, want nearly all", rejected)
+	}
+
+	c.ResetStats()
+	if _, ok := c.Get("absent:0"); ok {
+		t.Fatal("Get found a key that was never set")
+	}
+	if s := c.Stats(); s.Misses != 1 {
+		t.Fatalf("Misses = %d, want the rejected Get counted", s.Misses)
+	}
+}
+
+func TestBloomFilterFalsePositives(t *testing.T) {
+	// Far more keys than the filter is sized for, so it has false positives.
+	c := New(WithBloomFilter(10))
+	for i := 0; i < 500; i++ {
+		c.Set(strconv.Itoa(i), "v", time.Hour)
+	}
+
+	positives := 0
+	for i := 0; i < 100; i++ {
+		k := "absent:" + strconv.Itoa(i)
+		if !c.bloomFilter().mayContain(k) {
+			continue
+		}
+		positives++
+		if _, ok := c.Get(k); ok {
+			t.Fatalf("Get found %s after a false positive", k)
+		}
+	}
+	if positives == 0 {
+		t.Fatal("an overfull filter gave no false positives; the test checks nothing")
+	}
+	for i := 0; i < 500; i++ {
+		if _, ok := c.Get(strconv.Itoa(i)); !ok {
+			t.Fatalf("Get(%d) missed a stored key", i)
+		}
+	}
+}
+
+func TestBloomFilterDeleteAndFlush(t *testing.T) {
+	c := New(WithBloomFilter(100))
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", time.Hour)
+	c.Set("a", "3", time.Hour) // an update adds nothing to the filter
+
+	c.Delete("a")
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("deleting a took b out of the filter")
+	}
+	c.Delete("b")
+	for i, n := range c.bloomFilter().counters {
+		if n != 0 {
+			t.Fatalf("counter %d = %d after every key was deleted, want 0", i, n)
+		}
+	}
+
+	c.Set("c", "v", time.Hour)
+	c.Flush()
+	if c.bloomFilter().mayContain("c") {
+		t.Fatal("Flush did not rebuild the filter")
+	}
+	c.Set("d", "v", time.Hour)
+	if _, ok := c.Get("d"); !ok {
+		t.Fatal("Get missed a key set after Flush")
+	}
+
+	c.ReplaceAll(map[string]string{"e": "v"}, time.Hour)
+	if _, ok := c.Get("e"); !ok || c.bloomFilter().mayContain("d") {
+		t.Fatal("ReplaceAll did not rebuild the filter")
+	}
+	if v, ok := c.Snapshot().Get("e"); !ok || v != "v" {
+		t.Fatal("a snapshot's filter is missing its keys")
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "time"
+
+// SetBytes stores v under k like Set, without converting it to a string. The
+// cache keeps its own copy, so the caller may reuse v afterwards.
+func (c *Cache) SetBytes(k string, v []byte, expiry time.Duration) {
+	if c.isReadOnly() || c.rateLimited() {
+		return
+	}
+
+	val, compressed, err := c.encodeBytes(v)
+	if err != nil {
+		return
+	}
+	if c.setEncoded(k, val, compressed, expiry, c.lockWrite) == nil && c.store != nil {
+		c.flushSaves([]pendingSave{{k, string(v), c.lifetime(expiry)}})
+	}
+}
+
+// GetBytes is Get returning the value as a byte slice. The slice is a copy
+// the caller may modify without affecting the cache.
+func (c *Cache) GetBytes(k string) ([]byte, bool) {
+	e, err := c.getEncoded(k, c.lockForRead())
+	if err != nil {
+		return nil, false
+	}
+	b, err := c.decodeBytes(e.val, e.compressed)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBytesIsolation(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"compressed", []Option{WithCompression(1)}},
+		{"encrypted", []Option{WithEncryption(make([]byte, 16))}},
+	} {
+		c := New(tt.opts...)
+		in := []byte{0x00, 0xff, 0xfe, 0x01}
+		c.SetBytes("k", in, time.Hour)
+
+		// Reusing the caller's slice does not change the cached value.
+		in[0] = 0x42
+		got, ok := c.GetBytes("k")
+		if !ok || !bytes.Equal(got, []byte{0x00, 0xff, 0xfe, 0x01}) {
+			t.Fatalf("%s: GetBytes = %x, %v; want 00fffe01, true", tt.name, got, ok)
+		}
+
+		// Nor does modifying the returned slice.
+		got[1] = 0x42
+		if again, _ := c.GetBytes("k"); again[1] != 0xff {
+			t.Fatalf("%s: modifying the returned slice changed the cache: %x", tt.name, again)
+		}
+	}
+}
+
+func TestBytesAndStrings(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.SetBytes("b", []byte("bytes"), 0)
+	c.Set("s", "string", 0)
+
+	if v, ok := c.Get("b"); !ok || v != "bytes" {
+		t.Fatalf("Get(b) = %q, %v; want \"bytes\", true", v, ok)
+	}
+	if v, ok := c.GetBytes("s"); !ok || string(v) != "string" {
+		t.Fatalf("GetBytes(s) = %q, %v; want \"string\", true", v, ok)
+	}
+	if _, ok := c.GetBytes("missing"); ok {
+		t.Fatal("GetBytes found a missing key")
+	}
+}
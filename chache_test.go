@@ -1,9 +1,1369 @@
 package main
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 func BenchmarkMain(t *testing.B) {
 	for i := 0; i < t.N; i++ {
 		main()
 	}
 }
+
+func TestSetWhenFullDoesNotDeadlock(t *testing.T) {
+	maxItems := 10
+	c := NewCacheWithJanitor(time.Minute, maxItems)
+	defer c.Close()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i <= maxItems; i++ {
+			k := fmt.Sprintf("%d", i)
+			c.Set(k, k, time.Minute)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Set did not return once the cache was full")
+	}
+}
+
+func TestSetEvictsLiveItemsWhenFull(t *testing.T) {
+	maxItems := 50
+	c := NewCacheWithJanitor(time.Minute, maxItems)
+	defer c.Close()
+
+	for i := 0; i < maxItems+100; i++ {
+		k := fmt.Sprintf("%d", i)
+		c.Set(k, k, time.Hour)
+		if len(c.items) > maxItems {
+			t.Fatalf("after %d inserts the cache holds %d items, want at most %d", i+1, len(c.items), maxItems)
+		}
+	}
+
+	// The most recent write must always survive eviction.
+	k := fmt.Sprintf("%d", maxItems+99)
+	if _, ok := c.Get(k); !ok {
+		t.Fatalf("key %q was evicted by its own Set", k)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get found a deleted key")
+	}
+
+	// Deleting an absent key must not panic.
+	c.Delete("missing")
+}
+
+func TestDeleteReadOnly(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+	c.SaveAndExit("")
+
+	c.Delete("a")
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Fatalf("Get = %q, %v after Delete in read-only mode; want \"1\", true", v, ok)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	c := NewCache(time.Minute)
+	for i := 0; i < 300; i++ {
+		k := fmt.Sprintf("%d", i)
+		c.Set(k, k, time.Hour)
+	}
+
+	c.Flush()
+	if n := c.Len(); n != 0 {
+		t.Fatalf("Len = %d after Flush, want 0", n)
+	}
+	for i := 0; i < 300; i++ {
+		if _, ok := c.Get(fmt.Sprintf("%d", i)); ok {
+			t.Fatalf("key %d survived Flush", i)
+		}
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	sets := [2]map[string]string{{}, {}}
+	for i := 0; i < 200; i++ {
+		sets[0][fmt.Sprintf("k%d", i)] = "old"
+		sets[1][fmt.Sprintf("k%d", i+100)] = "new"
+	}
+	c := NewCache(time.Minute)
+	c.ReplaceAll(sets[0], time.Hour)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				items := c.Items()
+				if fmt.Sprint(items) != fmt.Sprint(sets[0]) && fmt.Sprint(items) != fmt.Sprint(sets[1]) {
+					t.Errorf("read saw a mix of old and new items: %d items", len(items))
+					return
+				}
+			}
+		}()
+	}
+	for i := 1; i <= 51; i++ {
+		c.ReplaceAll(sets[i%2], time.Hour)
+	}
+	close(done)
+	wg.Wait()
+
+	if n := c.Len(); n != 200 {
+		t.Fatalf("Len = %d after ReplaceAll, want 200", n)
+	}
+	if _, ok := c.Get("k0"); ok {
+		t.Fatal("an old item survived ReplaceAll")
+	}
+	if ttl, ok := c.TTL("k299"); !ok || ttl > time.Hour || ttl < time.Hour-time.Second {
+		t.Fatalf("TTL(k299) = %v, %v; want about 1h", ttl, ok)
+	}
+	c.Delete("k100")
+	if n := c.Len(); n != 199 {
+		t.Fatalf("Len = %d after deleting a replaced item, want 199", n)
+	}
+}
+
+func TestReplaceAllReportsEvictions(t *testing.T) {
+	var events []string
+	c := New(WithMaxItems(2), WithLogger(func(event string, fields map[string]any) {
+		events = append(events, event)
+	}))
+	evicted := 0
+	c.OnEvictedWithReason(func(key, value string, reason EvictionReason) {
+		if reason == ReasonCapacity {
+			evicted++
+		}
+	})
+
+	c.ReplaceAll(map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"}, time.Hour)
+	if n := c.Len(); n != 2 {
+		t.Fatalf("Len = %d after ReplaceAll, want 2", n)
+	}
+	if evicted != 3 {
+		t.Fatalf("OnEvicted saw %d capacity evictions, want 3", evicted)
+	}
+	if n := c.Stats().Evictions; n != 3 {
+		t.Fatalf("Stats().Evictions = %d, want 3", n)
+	}
+	if len(events) != 1 || events[0] != "evict" {
+		t.Fatalf("logged %v, want [evict]", events)
+	}
+}
+
+func TestExpireAll(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	for i := 0; i < 300; i++ {
+		k := fmt.Sprintf("%d", i)
+		expiry := time.Hour
+		if i%2 == 0 {
+			expiry = NoExpiration
+		}
+		c.Set(k, k, expiry)
+	}
+
+	c.ExpireAll()
+	for i := 0; i < 300; i++ {
+		if _, ok := c.Get(fmt.Sprintf("%d", i)); ok {
+			t.Fatalf("key %d survived ExpireAll", i)
+		}
+	}
+	c.Set("new", "v", time.Hour)
+	if _, ok := c.Get("new"); !ok {
+		t.Fatal("an item written after ExpireAll expired")
+	}
+
+	c.DeleteExpired()
+	if n := c.Size(); n != 1 {
+		t.Fatalf("Size after DeleteExpired = %d, want 1", n)
+	}
+}
+
+func TestLenExcludesExpiredItems(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("short1", "1", 50*time.Millisecond)
+	c.Set("short2", "2", 50*time.Millisecond)
+	c.Set("long", "3", time.Hour)
+
+	if n := c.Len(); n != 3 {
+		t.Fatalf("Len = %d, want 3", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if n := c.Len(); n != 1 {
+		t.Fatalf("Len = %d after expiry, want 1", n)
+	}
+	if n := c.ItemCount(); n != 3 {
+		t.Fatalf("ItemCount = %d after expiry, want 3", n)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("live1", "1", time.Hour)
+	c.Set("expired", "2", time.Millisecond)
+	c.Set("live2", "3", time.Hour)
+	time.Sleep(5 * time.Millisecond)
+
+	keys := c.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "live1" || keys[1] != "live2" {
+		t.Fatalf("Keys = %v, want [live1 live2]", keys)
+	}
+}
+
+func TestGetRemovesExpiredItem(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get returned an expired item")
+	}
+	if _, ok := c.items["a"]; ok {
+		t.Fatal("expired item is still in the map after Get")
+	}
+}
+
+func TestGetOrDeleteReturnsValue(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+
+	if v, ok := c.GetOrDelete("a"); !ok || v != "1" {
+		t.Fatalf("GetOrDelete = %q, %v; want \"1\", true", v, ok)
+	}
+}
+
+func TestHas(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("live", "1", time.Hour)
+	c.Set("expired", "2", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if !c.Has("live") {
+		t.Error("Has(live) = false, want true")
+	}
+	if c.Has("expired") {
+		t.Error("Has(expired) = true, want false")
+	}
+	if _, ok := c.items["expired"]; !ok {
+		t.Error("Has removed the expired item")
+	}
+	if c.Has("missing") {
+		t.Error("Has(missing) = true, want false")
+	}
+}
+
+func TestTouch(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", 20*time.Millisecond)
+
+	if !c.Touch("a", time.Hour) {
+		t.Fatal("Touch(a) = false, want true")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("touched key expired at its original TTL")
+	}
+
+	if c.Touch("missing", time.Hour) {
+		t.Fatal("Touch(missing) = true, want false")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+
+	ttl, ok := c.TTL("a")
+	if !ok || ttl > time.Hour || ttl < time.Hour-time.Second {
+		t.Fatalf("TTL = %v, %v; want about 1h, true", ttl, ok)
+	}
+
+	if ttl, ok := c.TTL("missing"); ok || ttl != 0 {
+		t.Fatalf("TTL(missing) = %v, %v; want 0, false", ttl, ok)
+	}
+
+	c.Set("expired", "2", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if ttl, ok := c.TTL("expired"); ok || ttl != 0 {
+		t.Fatalf("TTL(expired) = %v, %v; want 0, false", ttl, ok)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	c := NewCache(time.Minute)
+
+	if !c.Add("a", "1", time.Hour) {
+		t.Fatal("Add(a) on a fresh key = false, want true")
+	}
+	if c.Add("a", "2", time.Hour) {
+		t.Fatal("Add(a) on a live key = true, want false")
+	}
+	if v, _ := c.Get("a"); v != "1" {
+		t.Fatalf("Get(a) = %q, want \"1\"", v)
+	}
+
+	c.Set("expired", "old", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if !c.Add("expired", "new", time.Hour) {
+		t.Fatal("Add on an expired key = false, want true")
+	}
+	if v, _ := c.Get("expired"); v != "new" {
+		t.Fatalf("Get(expired) = %q, want \"new\"", v)
+	}
+}
+
+func TestAddReadOnly(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.SaveAndExit("")
+
+	if c.Add("a", "1", time.Hour) {
+		t.Fatal("Add in read-only mode = true, want false")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	c := NewCache(time.Minute)
+
+	if c.Replace("a", "1", time.Hour) {
+		t.Fatal("Replace on a missing key = true, want false")
+	}
+	if c.Has("a") {
+		t.Fatal("Replace created a missing key")
+	}
+
+	c.Set("a", "1", time.Hour)
+	if !c.Replace("a", "2", time.Hour) {
+		t.Fatal("Replace on a live key = false, want true")
+	}
+	if v, _ := c.Get("a"); v != "2" {
+		t.Fatalf("Get(a) = %q, want \"2\"", v)
+	}
+
+	c.Set("expired", "old", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if c.Replace("expired", "new", time.Hour) {
+		t.Fatal("Replace on an expired key = true, want false")
+	}
+}
+
+func TestReplaceReadOnly(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+	c.SaveAndExit("")
+
+	if c.Replace("a", "2", time.Hour) {
+		t.Fatal("Replace in read-only mode = true, want false")
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	c := NewCache(time.Minute)
+
+	if v, ok := c.GetSet("a", "1", time.Hour); ok || v != "" {
+		t.Fatalf("GetSet on a fresh key = %q, %v; want \"\", false", v, ok)
+	}
+	if v, ok := c.GetSet("a", "2", time.Hour); !ok || v != "1" {
+		t.Fatalf("GetSet = %q, %v; want \"1\", true", v, ok)
+	}
+	if v, ok := c.GetSet("a", "3", time.Hour); !ok || v != "2" {
+		t.Fatalf("GetSet = %q, %v; want \"2\", true", v, ok)
+	}
+	if v, _ := c.Get("a"); v != "3" {
+		t.Fatalf("Get(a) = %q, want \"3\"", v)
+	}
+
+	c.Set("expired", "old", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if v, ok := c.GetSet("expired", "new", time.Hour); ok || v != "" {
+		t.Fatalf("GetSet on an expired key = %q, %v; want \"\", false", v, ok)
+	}
+	if v, _ := c.Get("expired"); v != "new" {
+		t.Fatalf("Get(expired) = %q, want \"new\"", v)
+	}
+}
+
+func TestIncrementDecrement(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("n", "10", time.Hour)
+
+	if v, err := c.Increment("n", 5); err != nil || v != 15 {
+		t.Fatalf("Increment = %d, %v; want 15, nil", v, err)
+	}
+	if v, err := c.Decrement("n", 20); err != nil || v != -5 {
+		t.Fatalf("Decrement = %d, %v; want -5, nil", v, err)
+	}
+	if v, _ := c.Get("n"); v != "-5" {
+		t.Fatalf("Get(n) = %q, want \"-5\"", v)
+	}
+}
+
+func TestIncrementErrors(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("max", strconv.FormatInt(math.MaxInt64, 10), time.Hour)
+	c.Set("min", strconv.FormatInt(math.MinInt64, 10), time.Hour)
+	c.Set("text", "abc", time.Hour)
+	c.Set("expired", "1", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	tests := []struct {
+		name string
+		op   func() (int64, error)
+		want error
+	}{
+		{"overflow", func() (int64, error) { return c.Increment("max", 1) }, ErrOverflow},
+		{"underflow", func() (int64, error) { return c.Decrement("min", 1) }, ErrOverflow},
+		{"missing", func() (int64, error) { return c.Increment("missing", 1) }, ErrNotFound},
+		{"expired", func() (int64, error) { return c.Increment("expired", 1) }, ErrExpired},
+		{"not integer", func() (int64, error) { return c.Increment("text", 1) }, ErrNotInteger},
+	}
+	for _, tt := range tests {
+		if _, err := tt.op(); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if v, _ := c.Get("max"); v != strconv.FormatInt(math.MaxInt64, 10) {
+		t.Fatalf("overflowing Increment changed the value to %q", v)
+	}
+}
+
+func TestIncrementFloat(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("f", "0.1", time.Hour)
+	// Variables, so the sums are rounded like the cache's.
+	a, b, d := 0.1, 0.2, -1.3
+
+	if v, err := c.IncrementFloat("f", b); err != nil || v != a+b {
+		t.Fatalf("IncrementFloat = %v, %v; want %v, nil", v, err, a+b)
+	}
+	if v, _ := c.Get("f"); v != "0.30000000000000004" {
+		t.Fatalf("Get(f) = %q, want the shortest exact form \"0.30000000000000004\"", v)
+	}
+	if v, err := c.IncrementFloat("f", d); err != nil || v != a+b+d {
+		t.Fatalf("IncrementFloat with a negative delta = %v, %v; want %v, nil", v, err, a+b+d)
+	}
+	c.Set("n", "3", time.Hour)
+	if v, err := c.IncrementFloat("n", 1.5); err != nil || v != 4.5 {
+		t.Fatalf("IncrementFloat on an integer = %v, %v; want 4.5, nil", v, err)
+	}
+	if v, _ := c.Get("n"); v != "4.5" {
+		t.Fatalf("Get(n) = %q, want \"4.5\"", v)
+	}
+
+	c.Set("text", "abc", time.Hour)
+	c.Set("max", strconv.FormatFloat(math.MaxFloat64, 'g', -1, 64), time.Hour)
+	for _, tt := range []struct {
+		key  string
+		want error
+	}{
+		{"missing", ErrNotFound},
+		{"text", ErrNotFloat},
+		{"max", ErrOverflow},
+	} {
+		if _, err := c.IncrementFloat(tt.key, math.MaxFloat64); !errors.Is(err, tt.want) {
+			t.Errorf("IncrementFloat(%s) err = %v, want %v", tt.key, err, tt.want)
+		}
+	}
+}
+
+func TestIncrementFloatNonFinite(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("f", "1", time.Hour)
+	c.Set("nan", "NaN", time.Hour)
+	c.Set("inf", "+Inf", time.Hour)
+
+	for _, tt := range []struct {
+		key   string
+		delta float64
+		want  error
+	}{
+		{"f", math.NaN(), ErrNotFloat},
+		{"f", math.Inf(1), ErrOverflow},
+		{"f", math.Inf(-1), ErrOverflow},
+		{"nan", 1, ErrNotFloat},
+		{"inf", 1, ErrNotFloat},
+		{"inf", math.Inf(-1), ErrOverflow},
+	} {
+		if _, err := c.IncrementFloat(tt.key, tt.delta); !errors.Is(err, tt.want) {
+			t.Errorf("IncrementFloat(%s, %v) err = %v, want %v", tt.key, tt.delta, err, tt.want)
+		}
+	}
+	if v, _ := c.Get("f"); v != "1" {
+		t.Fatalf("Get(f) = %q after rejected increments, want \"1\"", v)
+	}
+}
+
+func TestSetIfGreater(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(time.Minute, WithClock(clock.Now))
+	steps := []struct {
+		v       int64
+		want    int64
+		changed bool
+	}{
+		{math.MinInt64, math.MinInt64, true}, // a missing key is -inf
+		{5, 5, true},
+		{5, 5, false},
+		{3, 5, false},
+		{-10, 5, false},
+		{8, 8, true},
+	}
+	for _, s := range steps {
+		if got, changed := c.SetIfGreater("hwm", s.v, time.Hour); got != s.want || changed != s.changed {
+			t.Fatalf("SetIfGreater(%d) = %d, %v; want %d, %v", s.v, got, changed, s.want, s.changed)
+		}
+	}
+	if v, _ := c.Get("hwm"); v != "8" {
+		t.Fatalf("Get(hwm) = %q, want \"8\"", v)
+	}
+
+	c.Set("text", "abc", time.Hour)
+	if _, changed := c.SetIfGreater("text", 1, time.Hour); changed {
+		t.Fatal("SetIfGreater replaced a value that is not an integer")
+	}
+	c.Set("expired", "100", time.Millisecond)
+	clock.Advance(5 * time.Millisecond)
+	if got, changed := c.SetIfGreater("expired", 1, time.Hour); got != 1 || !changed {
+		t.Fatalf("SetIfGreater on an expired item = %d, %v; want 1, true", got, changed)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	c.Set("log", "line1\n", time.Minute)
+
+	if n, err := c.Append("log", "line2\n", time.Hour); err != nil || n != 12 {
+		t.Fatalf("Append to an existing value = %d, %v; want 12, nil", n, err)
+	}
+	if v, _ := c.Get("log"); v != "line1\nline2\n" {
+		t.Fatalf("Get(log) = %q after Append", v)
+	}
+	if ttl, _ := c.TTL("log"); ttl != time.Hour {
+		t.Fatalf("TTL after Append = %v, want 1h", ttl)
+	}
+
+	if n, err := c.Append("new", "abc", 0); err != nil || n != 3 {
+		t.Fatalf("Append to a missing key = %d, %v; want 3, nil", n, err)
+	}
+	c.Set("old", "stale", time.Second)
+	clock.Advance(2 * time.Second)
+	if n, err := c.Append("old", "fresh", 0); err != nil || n != 5 {
+		t.Fatalf("Append to an expired key = %d, %v; want 5, nil", n, err)
+	}
+}
+
+func TestAppendConcurrent(t *testing.T) {
+	c := NewCache(time.Minute)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				c.Append("k", "ab", 0)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := c.Get("k"); len(v) != 8*100*2 {
+		t.Fatalf("len after concurrent Appends = %d, want %d", len(v), 8*100*2)
+	}
+}
+
+func TestGetNumbers(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("int", "-42", 0)
+	c.Set("float", "2.5", 0)
+	c.Set("text", "abc", 0)
+
+	if n, ok, err := c.GetInt("int"); n != -42 || !ok || err != nil {
+		t.Errorf("GetInt(int) = %d, %v, %v; want -42, true, nil", n, ok, err)
+	}
+	if _, ok, err := c.GetInt("float"); !ok || !errors.Is(err, ErrNotInteger) {
+		t.Errorf("GetInt(float) = _, %v, %v; want true, ErrNotInteger", ok, err)
+	}
+	if _, ok, err := c.GetInt("missing"); ok || err != nil {
+		t.Errorf("GetInt(missing) = _, %v, %v; want false, nil", ok, err)
+	}
+
+	if f, ok, err := c.GetFloat("float"); f != 2.5 || !ok || err != nil {
+		t.Errorf("GetFloat(float) = %v, %v, %v; want 2.5, true, nil", f, ok, err)
+	}
+	if f, ok, err := c.GetFloat("int"); f != -42 || !ok || err != nil {
+		t.Errorf("GetFloat(int) = %v, %v, %v; want -42, true, nil", f, ok, err)
+	}
+	if _, ok, err := c.GetFloat("text"); !ok || !errors.Is(err, ErrNotFloat) {
+		t.Errorf("GetFloat(text) = _, %v, %v; want true, ErrNotFloat", ok, err)
+	}
+	if _, ok, err := c.GetFloat("missing"); ok || err != nil {
+		t.Errorf("GetFloat(missing) = _, %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestNoExpiration(t *testing.T) {
+	c := NewCacheWithJanitor(5*time.Millisecond, 10)
+	defer c.Close()
+	c.Set("never", "2", NoExpiration)
+	time.Sleep(30 * time.Millisecond)
+
+	if v, ok := c.GetOrDelete("never"); !ok || v != "2" {
+		t.Fatalf("GetOrDelete(never) = %q, %v; want \"2\", true", v, ok)
+	}
+	if ttl, ok := c.TTL("never"); !ok || ttl != NoExpiration {
+		t.Fatalf("TTL(never) = %v, %v; want NoExpiration, true", ttl, ok)
+	}
+}
+
+func TestDefaultExpiration(t *testing.T) {
+	c := NewCache(20 * time.Millisecond)
+	c.Set("default", "1", DefaultExpiration)
+	c.SetDefault("setdefault", "2")
+	c.Set("explicit", "3", time.Hour)
+
+	if ttl, ok := c.TTL("setdefault"); !ok || ttl > 20*time.Millisecond {
+		t.Fatalf("TTL(setdefault) = %v, %v; want at most 20ms, true", ttl, ok)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get("default"); ok {
+		t.Error("item stored with DefaultExpiration outlived the default expiry")
+	}
+	if _, ok := c.Get("setdefault"); ok {
+		t.Error("item stored with SetDefault outlived the default expiry")
+	}
+	if _, ok := c.Get("explicit"); !ok {
+		t.Error("explicit TTL was overridden by the default expiry")
+	}
+}
+
+func TestCapacityIsEnforcedForAllWriters(t *testing.T) {
+	maxItems := 20
+	c := NewCacheWithJanitor(time.Minute, maxItems)
+	defer c.Close()
+
+	for i := 0; i < 3*maxItems; i++ {
+		k := fmt.Sprintf("%d", i)
+		switch i % 3 {
+		case 0:
+			c.Set(k, k, time.Hour)
+		case 1:
+			c.Add(k, k, time.Hour)
+		default:
+			c.GetSet(k, k, time.Hour)
+		}
+		if n := c.ItemCount(); n > maxItems {
+			t.Fatalf("after %d writes the cache holds %d items, want at most %d", i+1, n, maxItems)
+		}
+	}
+}
+
+func TestNewCacheIsUnbounded(t *testing.T) {
+	c := NewCache(time.Minute)
+	for i := 0; i < 500; i++ {
+		k := fmt.Sprintf("%d", i)
+		c.Set(k, k, time.Hour)
+	}
+	if n := c.Len(); n != 500 {
+		t.Fatalf("Len = %d, want 500", n)
+	}
+}
+
+func TestCloseStopsJanitor(t *testing.T) {
+	before := runtime.NumGoroutine()
+	c := NewCacheWithJanitor(time.Millisecond, 10)
+	c.Close()
+	c.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor still running: %d goroutines, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	c := NewCache(time.Minute, WithCompression(1024))
+	big := strings.Repeat("a", 1<<20)
+	c.Set("big", big, time.Hour)
+	c.Set("small", "tiny", time.Hour)
+
+	if v, ok := c.Get("big"); !ok || v != big {
+		t.Fatalf("Get(big) returned %d bytes, %v; want the original %d bytes", len(v), ok, len(big))
+	}
+	if it := c.items["big"]; !it.compressed || len(it.val) >= len(big) {
+		t.Fatalf("big value stored in %d bytes (compressed=%v), want fewer than %d", len(it.val), it.compressed, len(big))
+	}
+
+	if v, ok := c.Get("small"); !ok || v != "tiny" {
+		t.Fatalf("Get(small) = %q, %v; want \"tiny\", true", v, ok)
+	}
+	if c.items["small"].compressed {
+		t.Fatal("value below the threshold was compressed")
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", time.Hour)
+	c.Set("expired", "3", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	got := c.GetMulti([]string{"a", "b", "expired", "missing"})
+	if len(got) != 2 || got["a"] != "1" || got["b"] != "2" {
+		t.Fatalf("GetMulti = %v, want map[a:1 b:2]", got)
+	}
+}
+
+func TestSetMulti(t *testing.T) {
+	c := NewCache(time.Minute)
+	entries := map[string]string{"a": "1", "b": "2", "c": "3"}
+	c.SetMulti(entries, time.Hour)
+
+	for k, want := range entries {
+		if v, ok := c.Get(k); !ok || v != want {
+			t.Fatalf("Get(%s) = %q, %v; want %q, true", k, v, ok, want)
+		}
+	}
+}
+
+func TestSetMultiHonorsCapacity(t *testing.T) {
+	maxItems := 10
+	c := NewCacheWithJanitor(time.Minute, maxItems)
+	defer c.Close()
+	for i := 0; i < maxItems; i++ {
+		k := fmt.Sprintf("old%d", i)
+		c.Set(k, k, time.Hour)
+	}
+
+	entries := map[string]string{}
+	for i := 0; i < maxItems+5; i++ {
+		k := fmt.Sprintf("new%d", i)
+		entries[k] = k
+	}
+	c.SetMulti(entries, time.Hour)
+
+	if n := c.ItemCount(); n > maxItems {
+		t.Fatalf("cache holds %d items, want at most %d", n, maxItems)
+	}
+}
+
+func TestSetMultiReadOnly(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.SaveAndExit("")
+	c.SetMulti(map[string]string{"a": "1"}, time.Hour)
+	if c.Has("a") {
+		t.Fatal("SetMulti wrote in read-only mode")
+	}
+}
+
+func TestSaveAndExitTwiceStaysReadOnly(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.SaveAndExit("")
+	c.SaveAndExit("")
+
+	c.Set("a", "1", time.Hour)
+	if c.Has("a") {
+		t.Fatal("Set wrote after SaveAndExit was called twice")
+	}
+}
+
+func TestResume(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.SaveAndExit("")
+	c.Set("a", "1", time.Hour)
+	if c.Has("a") {
+		t.Fatal("Set wrote in read-only mode")
+	}
+
+	c.Resume()
+	c.Set("a", "1", time.Hour)
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Fatalf("Get(a) after Resume = %q, %v; want \"1\", true", v, ok)
+	}
+}
+
+func TestRange(t *testing.T) {
+	c := NewCache(time.Minute)
+	for i := 1; i <= 10; i++ {
+		c.Set(strconv.Itoa(i), strconv.Itoa(i), time.Hour)
+	}
+	c.Set("expired", "100", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	sum := 0
+	c.Range(func(key, value string) bool {
+		n, _ := strconv.Atoi(value)
+		sum += n
+		return true
+	})
+	if sum != 55 {
+		t.Fatalf("sum of values = %d, want 55", sum)
+	}
+}
+
+func TestRangeStopsEarly(t *testing.T) {
+	c := NewCache(time.Minute)
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), "v", time.Hour)
+	}
+
+	calls := 0
+	c.Range(func(key, value string) bool {
+		calls++
+		return calls < 3
+	})
+	if calls != 3 {
+		t.Fatalf("f called %d times, want 3", calls)
+	}
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	c := NewCache(time.Minute, WithSlidingExpiration())
+	c.Set("a", "1", 50*time.Millisecond)
+
+	for i := 0; i < 8; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if _, ok := c.Get("a"); !ok {
+			t.Fatalf("item expired after %d reads despite sliding expiration", i)
+		}
+	}
+
+	time.Sleep(70 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("item did not expire once reads stopped")
+	}
+}
+
+func TestGetWithExpiry(t *testing.T) {
+	c := NewCache(time.Minute)
+	want := time.Now().Add(time.Hour)
+	c.Set("a", "1", time.Hour)
+	c.Set("never", "2", NoExpiration)
+
+	v, expiry, ok := c.GetWithExpiry("a")
+	if !ok || v != "1" {
+		t.Fatalf("GetWithExpiry(a) = %q, _, %v; want \"1\", _, true", v, ok)
+	}
+	if d := expiry.Sub(want); d < -time.Second || d > time.Second {
+		t.Fatalf("expiry = %v, want about %v", expiry, want)
+	}
+
+	if v, expiry, ok := c.GetWithExpiry("never"); !ok || v != "2" || !expiry.IsZero() {
+		t.Fatalf("GetWithExpiry(never) = %q, %v, %v; want \"2\", zero time, true", v, expiry, ok)
+	}
+	if _, _, ok := c.GetWithExpiry("missing"); ok {
+		t.Fatal("GetWithExpiry(missing) reported found")
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+
+	if c.CompareAndSwap("a", "wrong", "2", time.Hour) {
+		t.Fatal("CompareAndSwap with a mismatching old value = true")
+	}
+	if !c.CompareAndSwap("a", "1", "2", time.Hour) {
+		t.Fatal("CompareAndSwap with a matching old value = false")
+	}
+	if v, _ := c.Get("a"); v != "2" {
+		t.Fatalf("Get(a) = %q, want \"2\"", v)
+	}
+	if c.CompareAndSwap("missing", "", "x", time.Hour) {
+		t.Fatal("CompareAndSwap on a missing key = true")
+	}
+}
+
+func TestCompareAndSwapRace(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "0", time.Hour)
+
+	var wins int32
+	var wg sync.WaitGroup
+	for _, v := range []string{"x", "y"} {
+		wg.Add(1)
+		go func(v string) {
+			defer wg.Done()
+			if c.CompareAndSwap("a", "0", v, time.Hour) {
+				atomic.AddInt32(&wins, 1)
+			}
+		}(v)
+	}
+	wg.Wait()
+
+	if wins != 1 {
+		t.Fatalf("%d swaps won, want exactly 1", wins)
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+
+	// Another writer changes the value between our read and delete.
+	seen, _ := c.Get("a")
+	c.Set("a", "2", time.Hour)
+	if c.CompareAndDelete("a", seen) {
+		t.Fatal("CompareAndDelete removed a value that changed")
+	}
+	if v, _ := c.Get("a"); v != "2" {
+		t.Fatalf("Get(a) = %q, want \"2\"", v)
+	}
+
+	if !c.CompareAndDelete("a", "2") {
+		t.Fatal("CompareAndDelete with the current value = false")
+	}
+	if c.Has("a") {
+		t.Fatal("CompareAndDelete left the item in place")
+	}
+}
+
+func TestCompareAndDeleteReadOnly(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+	c.SaveAndExit("")
+
+	if c.CompareAndDelete("a", "1") {
+		t.Fatal("CompareAndDelete in read-only mode = true")
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	c := NewCache(time.Minute)
+	for i := 0; i < 5; i++ {
+		c.Set(strconv.Itoa(i), "v", time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	c.DeleteExpired()
+	if n := c.ItemCount(); n != 0 {
+		t.Fatalf("cache holds %d items after DeleteExpired, want 0", n)
+	}
+}
+
+func TestJanitorInterval(t *testing.T) {
+	c := NewCacheWithJanitor(time.Hour, 0, WithJanitorInterval(5*time.Millisecond))
+	defer c.Close()
+	c.Set("long", "1", time.Hour)
+	c.Set("short", "2", time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for c.ItemCount() > 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("janitor did not reap the expired item")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !c.Has("long") {
+		t.Fatal("janitor reaped a live item")
+	}
+}
+
+// fakeClock is a manually advanced clock for WithClock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1000000, 0)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func TestWithClock(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(time.Minute, WithClock(clock.Now))
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", 2*time.Hour)
+
+	if ttl, _ := c.TTL("a"); ttl != time.Hour {
+		t.Fatalf("TTL(a) = %v, want exactly 1h", ttl)
+	}
+
+	clock.Advance(time.Hour)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("item expired at, rather than after, its expiry")
+	}
+
+	clock.Advance(time.Nanosecond)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get returned an item past its expiry")
+	}
+	if n := c.Len(); n != 1 {
+		t.Fatalf("Len = %d, want 1", n)
+	}
+
+	clock.Advance(time.Hour)
+	c.DeleteExpired()
+	if n := c.ItemCount(); n != 0 {
+		t.Fatalf("cache holds %d items after DeleteExpired, want 0", n)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	c := NewCache(time.Minute)
+	for _, k := range []string{"user:1:session", "user:1:profile", "user:12:session", "user:2:session", "other"} {
+		c.Set(k, "v", time.Hour)
+	}
+
+	if n := c.DeletePrefix("user:1:"); n != 2 {
+		t.Fatalf("DeletePrefix = %d, want 2", n)
+	}
+	keys := c.Keys()
+	sort.Strings(keys)
+	if want := []string{"other", "user:12:session", "user:2:session"}; fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Fatalf("Keys = %v, want %v", keys, want)
+	}
+
+	c.SaveAndExit("")
+	if n := c.DeletePrefix("user:"); n != 0 {
+		t.Fatalf("DeletePrefix in read-only mode = %d, want 0", n)
+	}
+}
+
+func TestDeleteMulti(t *testing.T) {
+	c := NewCache(time.Minute)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		c.Set(k, "v", time.Hour)
+	}
+	// OnEvicted runs once the lock is released, so if every key is gone
+	// by the first callback they were all removed under one lock.
+	var calls int
+	c.OnEvicted(func(key, value string) {
+		if calls++; calls == 1 && (c.Has("a") || c.Has("b") || c.Has("c")) {
+			t.Errorf("key left in the cache when %s was reported deleted", key)
+		}
+	})
+
+	if n := c.DeleteMulti([]string{"a", "missing", "b", "c", "a"}); n != 3 {
+		t.Fatalf("DeleteMulti = %d, want 3", n)
+	}
+	if calls != 3 {
+		t.Fatalf("OnEvicted called %d times, want 3", calls)
+	}
+	if keys := c.Keys(); len(keys) != 1 || keys[0] != "d" {
+		t.Fatalf("Keys = %v, want [d]", keys)
+	}
+
+	c.SaveAndExit("")
+	if n := c.DeleteMulti([]string{"d"}); n != 0 || !c.Has("d") {
+		t.Fatalf("DeleteMulti in read-only mode = %d, want 0", n)
+	}
+}
+
+func TestItemsReturnsCopy(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(time.Minute, WithClock(clock.Now))
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", time.Hour)
+	c.Set("expired", "3", time.Second)
+	clock.Advance(time.Minute)
+
+	items := c.Items()
+	if len(items) != 2 || items["a"] != "1" || items["b"] != "2" {
+		t.Fatalf("Items = %v, want map[a:1 b:2]", items)
+	}
+
+	items["a"] = "changed"
+	delete(items, "b")
+	items["new"] = "x"
+	if v, _ := c.Get("a"); v != "1" {
+		t.Fatalf("Get(a) = %q after mutating Items, want \"1\"", v)
+	}
+	if !c.Has("b") || c.Has("new") {
+		t.Fatal("mutating the Items map changed the cache")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(time.Minute, WithClock(clock.Now))
+	written := clock.Now()
+	c.Set("a", "1", time.Hour)
+
+	created, accessed, ok := c.Metadata("a")
+	if !ok || !created.Equal(written) || !accessed.Equal(written) {
+		t.Fatalf("Metadata = %v, %v, %v; want %v, %v, true", created, accessed, ok, written, written)
+	}
+
+	clock.Advance(time.Minute)
+	read := clock.Now()
+	c.Get("a")
+	created, accessed, _ = c.Metadata("a")
+	if !created.Equal(written) || !accessed.Equal(read) {
+		t.Fatalf("Metadata after Get = %v, %v; want %v, %v", created, accessed, written, read)
+	}
+
+	if _, _, ok := c.Metadata("missing"); ok {
+		t.Fatal("Metadata(missing) reported found")
+	}
+}
+
+func TestSetReturning(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(time.Minute, WithClock(clock.Now))
+
+	if prev, replaced := c.SetReturning("a", "1", time.Hour); replaced || prev != "" {
+		t.Fatalf("SetReturning on a fresh key = %q, %v; want \"\", false", prev, replaced)
+	}
+	if prev, replaced := c.SetReturning("a", "2", time.Second); !replaced || prev != "1" {
+		t.Fatalf("SetReturning on a live key = %q, %v; want \"1\", true", prev, replaced)
+	}
+
+	clock.Advance(time.Minute)
+	if prev, replaced := c.SetReturning("a", "3", time.Hour); replaced || prev != "" {
+		t.Fatalf("SetReturning on an expired key = %q, %v; want \"\", false", prev, replaced)
+	}
+	if v, _ := c.Get("a"); v != "3" {
+		t.Fatalf("Get(a) = %q, want \"3\"", v)
+	}
+}
+
+func TestNewCacheFromMap(t *testing.T) {
+	seed := map[string]string{"a": "1", "b": "2"}
+	c := NewCacheFromMap(seed, time.Hour)
+
+	seed["a"] = "changed"
+	seed["c"] = "3"
+	delete(seed, "b")
+
+	if got := c.Items(); len(got) != 2 || got["a"] != "1" || got["b"] != "2" {
+		t.Fatalf("Items = %v, want map[a:1 b:2]", got)
+	}
+	if ttl, _ := c.TTL("a"); ttl > time.Hour || ttl < time.Hour-time.Second {
+		t.Fatalf("TTL(a) = %v, want about 1h", ttl)
+	}
+}
+
+func TestSizeMatchesMap(t *testing.T) {
+	c := NewCacheWithJanitor(time.Millisecond, 500, WithJanitorInterval(time.Millisecond))
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				k := strconv.Itoa((g*7919 + i) % 1000)
+				switch i % 5 {
+				case 0:
+					c.Delete(k)
+				case 1:
+					c.Set(k, k, time.Millisecond)
+				default:
+					c.Set(k, k, time.Hour)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if got, want := c.Size(), len(c.items); got != want {
+		t.Fatalf("Size = %d, map holds %d items", got, want)
+	}
+}
+
+func TestPauseJanitor(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCacheWithJanitor(time.Minute, 0, WithClock(clock.Now), WithJanitorInterval(time.Millisecond))
+	defer c.Close()
+	c.PauseJanitor()
+
+	c.Set("a", "1", time.Second)
+	clock.Advance(time.Minute)
+	time.Sleep(20 * time.Millisecond)
+	if n := c.ItemCount(); n != 1 {
+		t.Fatalf("paused janitor reaped items: %d left, want 1", n)
+	}
+
+	c.ResumeJanitor()
+	deadline := time.Now().Add(time.Second)
+	for c.ItemCount() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("resumed janitor did not reap the expired item")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSetExpiry(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(time.Minute, WithClock(clock.Now))
+	c.Set("extend", "1", time.Minute)
+	c.Set("shorten", "2", time.Hour)
+
+	if !c.SetExpiry("extend", time.Hour) || !c.SetExpiry("shorten", time.Second) {
+		t.Fatal("SetExpiry on a live key = false, want true")
+	}
+	if ttl, _ := c.TTL("shorten"); ttl != time.Second {
+		t.Fatalf("TTL(shorten) = %v, want 1s", ttl)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if !c.Has("extend") {
+		t.Fatal("extended item expired at its original TTL")
+	}
+	if c.Has("shorten") {
+		t.Fatal("shortened item outlived its new TTL")
+	}
+	if c.SetExpiry("shorten", time.Hour) || c.SetExpiry("missing", time.Hour) {
+		t.Fatal("SetExpiry on an expired or missing key = true, want false")
+	}
+
+	c.SaveAndExit("")
+	if c.SetExpiry("extend", time.Second) {
+		t.Fatal("SetExpiry in read-only mode = true, want false")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithMaxBytes(8))
+	c.Set("k", "v", time.Minute)
+	c.Set("short", "v", time.Second)
+	clock.Advance(2 * time.Second)
+
+	tests := []struct {
+		name string
+		op   func() error
+		want error
+	}{
+		{"lookup missing", func() error { _, err := c.Lookup("missing"); return err }, ErrNotFound},
+		{"lookup expired", func() error { _, err := c.Lookup("short"); return err }, ErrExpired},
+		{"replace missing", func() error { return c.TryReplace("missing", "v", 0) }, ErrNotFound},
+		{"set too large", func() error { return c.TrySet("big", "0123456789", 0) }, ErrCapacity},
+	}
+	for _, tt := range tests {
+		if err := tt.op(); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, ok := c.Get("big"); ok {
+		t.Fatal("rejected TrySet stored the value")
+	}
+
+	c.SaveAndExit("")
+	if err := c.TrySet("k", "w", 0); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("TrySet in read-only mode: err = %v, want ErrReadOnly", err)
+	}
+	if v, err := c.Lookup("k"); err != nil || v != "v" {
+		t.Fatalf("Lookup(k) = %q, %v; want \"v\", nil", v, err)
+	}
+}
+
+func TestGetStale(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	c.Set("fresh", "1", time.Hour)
+	c.Set("stale", "2", time.Second)
+	clock.Advance(2 * time.Second)
+
+	tests := []struct {
+		key       string
+		want      string
+		wantStale bool
+		wantOK    bool
+	}{
+		{"fresh", "1", false, true},
+		{"stale", "2", true, true},
+		{"missing", "", false, false},
+	}
+	for _, tt := range tests {
+		v, stale, ok := c.GetStale(tt.key)
+		if v != tt.want || stale != tt.wantStale || ok != tt.wantOK {
+			t.Errorf("GetStale(%s) = %q, %v, %v; want %q, %v, %v",
+				tt.key, v, stale, ok, tt.want, tt.wantStale, tt.wantOK)
+		}
+	}
+	if _, ok := c.Get("stale"); ok {
+		t.Fatal("Get returned an expired item after GetStale")
+	}
+}
+
+func TestMatchKeys(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	for _, k := range []string{"user:1:active", "user:2:active", "user:10:active", "user:1:idle", "session:1", "user:3:active:old"} {
+		c.Set(k, "v", time.Hour)
+	}
+	c.Set("user:9:active", "expired", time.Second)
+	clock.Advance(2 * time.Second)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"user:*:active", []string{"user:10:active", "user:1:active", "user:2:active"}},
+		{"user:?:active", []string{"user:1:active", "user:2:active"}},
+		{"user:1:*", []string{"user:1:active", "user:1:idle"}},
+		{"session:[0-9]", []string{"session:1"}},
+		{"nothing*", nil},
+	}
+	for _, tt := range tests {
+		got, err := c.MatchKeys(tt.pattern)
+		if err != nil {
+			t.Errorf("MatchKeys(%q): %v", tt.pattern, err)
+			continue
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MatchKeys(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+
+	if _, err := c.MatchKeys("user:[1"); !errors.Is(err, filepath.ErrBadPattern) {
+		t.Fatalf("MatchKeys with a malformed pattern: err = %v, want ErrBadPattern", err)
+	}
+}
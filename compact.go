@@ -0,0 +1,40 @@
+package main
+
+// defaultCompactThreshold is the fraction of its peak size the items map
+// must shrink below before Compact rebuilds it.
+const defaultCompactThreshold = 0.25
+
+// WithCompactThreshold sets the fraction of the largest item count since the
+// last rebuild below which Compact rebuilds the items map. Zero or negative
+// disables compaction by the janitor.
+func WithCompactThreshold(f float64) Option {
+	return func(c *Cache) {
+		c.compactThreshold = f
+	}
+}
+
+// Compact releases the memory held by the items map after most of its items
+// have been removed. Go maps never shrink, so once the live items fall below
+// the compact threshold of the peak item count, the map is rebuilt at its
+// current size. Compact reports whether it rebuilt the map. The janitor calls
+// it after every sweep.
+func (c *Cache) Compact() bool {
+	c.mu.Lock()
+	defer c.unlock()
+	return c.compactLocked()
+}
+
+// compactLocked rebuilds the items map if it has shrunk enough. The caller
+// must hold the write lock.
+func (c *Cache) compactLocked() bool {
+	if c.compactThreshold <= 0 || float64(len(c.items)) >= float64(c.peakItems)*c.compactThreshold {
+		return false
+	}
+	items := make(map[string]*item, len(c.items))
+	for k, it := range c.items {
+		items[k] = it
+	}
+	c.items = items
+	c.peakItems = len(items)
+	return true
+}
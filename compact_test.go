@@ -0,0 +1,74 @@
+package main
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+func TestCompact(t *testing.T) {
+	n := 1000000
+	if testing.Short() {
+		n = 10000
+	}
+	c := New()
+	for i := 0; i < n; i++ {
+		c.Set(strconv.Itoa(i), "v", 0)
+	}
+	if c.Compact() {
+		t.Fatal("Compact rebuilt a full map")
+	}
+
+	// Keep every hundredth key.
+	for i := 0; i < n; i++ {
+		if i%100 != 0 {
+			c.Delete(strconv.Itoa(i))
+		}
+	}
+	before := heapAlloc()
+	if !c.Compact() {
+		t.Fatal("Compact did not rebuild a map with 1% of its peak")
+	}
+	after := heapAlloc()
+	if !testing.Short() && after >= before {
+		t.Errorf("heap grew from %d to %d bytes after Compact", before, after)
+	}
+
+	if got := c.Size(); got != n/100 {
+		t.Fatalf("Size after Compact = %d, want %d", got, n/100)
+	}
+	for i := 0; i < n; i += 100 {
+		if !c.Has(strconv.Itoa(i)) {
+			t.Fatalf("key %d lost by Compact", i)
+		}
+	}
+	if c.Compact() {
+		t.Fatal("second Compact rebuilt the map again")
+	}
+}
+
+func TestCompactThreshold(t *testing.T) {
+	c := New(WithCompactThreshold(0.9))
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), "v", 0)
+	}
+	c.Delete("0")
+	c.Delete("1")
+	if !c.Compact() {
+		t.Fatal("Compact did not rebuild at 80% of the peak with a 90% threshold")
+	}
+
+	off := New(WithCompactThreshold(0))
+	off.Set("a", "v", 0)
+	off.Delete("a")
+	if off.Compact() {
+		t.Fatal("Compact rebuilt the map with compaction disabled")
+	}
+}
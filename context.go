@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// lockRetryInterval is how long the context-aware methods wait between
+// attempts to take a contended lock.
+const lockRetryInterval = 50 * time.Microsecond
+
+// GetContext is like Get but gives up with ctx.Err() if ctx is done before
+// the lock can be taken.
+func (c *Cache) GetContext(ctx context.Context, k string) (string, bool, error) {
+	unlock, err := c.lockForReadContext(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	v, _, err := c.get(k, unlock)
+	return v, err == nil, nil
+}
+
+// SetContext is like TrySet but gives up with ctx.Err() if ctx is done
+// before the lock can be taken.
+func (c *Cache) SetContext(ctx context.Context, k, v string, expiry time.Duration) error {
+	return c.trySet(k, v, expiry, func() error {
+		return lockContext(ctx, c.mu.TryLock)
+	})
+}
+
+// lockForReadContext is like lockForRead but gives up when ctx is done.
+func (c *Cache) lockForReadContext(ctx context.Context) (unlock func(), err error) {
+	if c.readsMutate() {
+		if err := lockContext(ctx, c.mu.TryLock); err != nil {
+			return nil, err
+		}
+		return c.unlock, nil
+	}
+	if err := lockContext(ctx, c.mu.TryRLock); err != nil {
+		return nil, err
+	}
+	return c.mu.RUnlock, nil
+}
+
+// lockContext calls tryLock until it succeeds or ctx is done.
+func lockContext(ctx context.Context, tryLock func() bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if tryLock() {
+		return nil
+	}
+
+	t := time.NewTicker(lockRetryInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if tryLock() {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetSetContext(t *testing.T) {
+	c := NewCache(time.Minute)
+	ctx := context.Background()
+
+	if err := c.SetContext(ctx, "a", "1", time.Hour); err != nil {
+		t.Fatalf("SetContext: %v", err)
+	}
+	if v, ok, err := c.GetContext(ctx, "a"); err != nil || !ok || v != "1" {
+		t.Fatalf("GetContext = %q, %v, %v; want \"1\", true, nil", v, ok, err)
+	}
+}
+
+func TestContextCanceledWhileLocked(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, _, err := c.GetContext(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetContext err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := c.SetContext(ctx, "a", "1", time.Hour); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SetContext err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("canceled operations took %v to return", d)
+	}
+}
+
+func TestContextAlreadyCanceled(t *testing.T) {
+	c := NewCache(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := c.SetContext(ctx, "a", "1", time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SetContext err = %v, want %v", err, context.Canceled)
+	}
+	if c.Has("a") {
+		t.Fatal("SetContext wrote with a canceled context")
+	}
+}
+
+func TestSetContextRejections(t *testing.T) {
+	ctx := context.Background()
+
+	c := New(WithMaxItems(1), WithAdmissionPolicy(TinyLFU))
+	c.Set("a", "1", time.Hour)
+	c.Get("a")
+	if err := c.SetContext(ctx, "b", "2", time.Hour); !errors.Is(err, ErrCapacity) {
+		t.Fatalf("SetContext of a colder key: err = %v, want ErrCapacity", err)
+	}
+
+	c = New(WithMaxBytes(8))
+	if err := c.SetContext(ctx, "big", "0123456789", 0); !errors.Is(err, ErrCapacity) {
+		t.Fatalf("SetContext of a value over the byte limit: err = %v, want ErrCapacity", err)
+	}
+
+	c = New(WithMinTTL(time.Second))
+	if err := c.SetContext(ctx, "short", "v", time.Millisecond); !errors.Is(err, ErrTTLTooShort) {
+		t.Fatalf("SetContext with a short expiry: err = %v, want ErrTTLTooShort", err)
+	}
+	if c.Has("short") {
+		t.Fatal("SetContext stored an item with a rejected expiry")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import "time"
+
+// defaultCost is the cost of items stored without SetWithCost.
+const defaultCost = 1
+
+// WithMaxCost limits the summed cost of the items in the cache to n. When a
+// write goes over the limit, items are evicted according to the eviction
+// policy until the cache fits; use EvictLowestCost to remove the cheapest
+// ones first. Zero means no limit.
+func WithMaxCost(n int64) Option {
+	return func(c *Cache) {
+		c.maxCost = n
+	}
+}
+
+// SetWithCost stores v under k like Set and records how expensive it is to
+// rebuild. Items stored by other writes cost 1, and overwriting an existing
+// item with Set keeps its cost. A value whose cost alone is over the
+// WithMaxCost limit is not stored.
+func (c *Cache) SetWithCost(k, v string, expiry time.Duration, cost int64) {
+	if c.isReadOnly() || (c.maxCost > 0 && cost > c.maxCost) || c.rateLimited() {
+		return
+	}
+
+	val, compressed, err := c.encode(v)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	if !c.setLocked(k, val, compressed, expiry) {
+		return
+	}
+	if it, ok := c.items[k]; ok {
+		c.cost += cost - it.cost
+		it.cost = cost
+		c.shrinkLocked()
+		c.saveLocked(k, v)
+	}
+}
+
+// cheapestLocked returns the item with the lowest cost, preferring the
+// older one on a tie. The caller must hold the write lock.
+func (c *Cache) cheapestLocked() *item {
+	least := c.order.back()
+	for it := least; it != nil; it = it.prev {
+		if it.cost < least.cost {
+			least = it
+		}
+	}
+	return least
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvictLowestCost(t *testing.T) {
+	c := New(WithMaxItems(3), WithEvictionPolicy(EvictLowestCost))
+	c.SetWithCost("expensive", "1", time.Hour, 100)
+	c.SetWithCost("cheap", "2", time.Hour, 1)
+	c.SetWithCost("medium", "3", time.Hour, 10)
+
+	c.SetWithCost("new", "4", time.Hour, 50)
+	if c.Has("cheap") {
+		t.Fatal("the cheapest item survived eviction")
+	}
+	c.SetWithCost("newer", "5", time.Hour, 50)
+	if c.Has("medium") {
+		t.Fatal("medium survived eviction with only costlier items left")
+	}
+	for _, k := range []string{"expensive", "new", "newer"} {
+		if !c.Has(k) {
+			t.Errorf("%s was evicted", k)
+		}
+	}
+}
+
+func TestMaxCost(t *testing.T) {
+	c := New(WithMaxCost(10), WithEvictionPolicy(EvictLowestCost))
+	c.SetWithCost("a", "1", time.Hour, 6)
+	c.SetWithCost("b", "2", time.Hour, 2)
+	c.Set("c", "3", time.Hour) // costs 1
+	if c.cost != 9 {
+		t.Fatalf("total cost = %d, want 9", c.cost)
+	}
+
+	c.SetWithCost("d", "4", time.Hour, 3)
+	// 12 is over the limit: c (1) and then b (2) are the cheapest.
+	if c.Has("c") || c.Has("b") {
+		t.Fatal("low-cost items survived going over the cost limit")
+	}
+	if !c.Has("a") || !c.Has("d") || c.cost != 9 {
+		t.Fatalf("Has(a) = %v, Has(d) = %v, cost = %d; want true, true, 9", c.Has("a"), c.Has("d"), c.cost)
+	}
+
+	c.SetWithCost("huge", "5", time.Hour, 11)
+	if c.Has("huge") {
+		t.Fatal("an item costing more than the limit was stored")
+	}
+	c.Delete("a")
+	c.Flush()
+	if c.cost != 0 {
+		t.Fatalf("cost after Flush = %d, want 0", c.cost)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WithEncryption encrypts values in memory with AES-GCM under key, so a
+// memory dump does not reveal them. Each value is sealed with its own random
+// nonce, which is stored in front of the ciphertext. The API still takes and
+// returns plaintext. key must be 16, 24 or 32 bytes long, selecting AES-128,
+// AES-192 or AES-256; with any other key every write fails, TrySet reporting
+// why, rather than storing values unencrypted.
+func WithEncryption(key []byte) Option {
+	return func(c *Cache) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			c.aead, c.aeadErr = nil, fmt.Errorf("cache: WithEncryption: %w", err)
+			return
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			c.aead, c.aeadErr = nil, fmt.Errorf("cache: WithEncryption: %w", err)
+			return
+		}
+		c.aead, c.aeadErr = aead, nil
+	}
+}
+
+var errCiphertext = errors.New("cache: stored value too short to decrypt")
+
+// encrypt seals val, returning the nonce followed by the ciphertext.
+func (c *Cache) encrypt(val []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	out := make([]byte, n, n+len(val)+c.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, out); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(out, out, val, nil), nil
+}
+
+// decrypt opens a value sealed by encrypt.
+func (c *Cache) decrypt(val []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(val) < n {
+		return nil, errCiphertext
+	}
+	return c.aead.Open(nil, val[:n], val[n:], nil)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryption(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	c := New(WithEncryption(key), WithCompression(64))
+	short := "secret-token"
+	long := strings.Repeat("secret-token ", 20)
+	c.Set("short", short, time.Hour)
+	c.Set("long", long, time.Hour)
+
+	for k, plain := range map[string]string{"short": short, "long": long} {
+		stored := c.items[k].val
+		if bytes.Contains(stored, []byte("secret-token")) {
+			t.Errorf("%s is stored in plaintext", k)
+		}
+		if v, ok := c.Get(k); !ok || v != plain {
+			t.Errorf("Get(%s) = %q, %v; want the original value", k, v, ok)
+		}
+	}
+
+	// The same value encrypts differently each time.
+	c.Set("again", short, time.Hour)
+	if bytes.Equal(c.items["again"].val, c.items["short"].val) {
+		t.Fatal("two writes of the same value produced the same ciphertext")
+	}
+	if !c.CompareAndSwap("again", short, "new", time.Hour) {
+		t.Fatal("CompareAndSwap on an encrypted value failed")
+	}
+}
+
+func TestWithEncryptionBadKey(t *testing.T) {
+	c := New(WithEncryption([]byte("short")))
+	var keyErr aes.KeySizeError
+	if err := c.TrySet("a", "1", time.Hour); !errors.As(err, &keyErr) {
+		t.Fatalf("TrySet with a 5-byte key: err = %v, want an aes.KeySizeError", err)
+	}
+	c.Set("b", "2", time.Hour)
+	if c.Len() != 0 {
+		t.Fatal("a cache with a bad encryption key stored values")
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the error-returning cache methods, possibly wrapped
+// with the operation and key; test for them with errors.Is.
+var (
+	ErrNotFound       = errors.New("cache: key not found")
+	ErrExpired        = errors.New("cache: key expired")
+	ErrReadOnly       = errors.New("cache: cache is read-only")
+	ErrNotInteger     = errors.New("cache: value is not an integer")
+	ErrNotFloat       = errors.New("cache: value is not a number")
+	ErrOverflow       = errors.New("cache: integer overflow")
+	ErrCapacity       = errors.New("cache: value does not fit in the cache")
+	ErrValueTooLarge  = errors.New("cache: value exceeds the maximum value size")
+	ErrRateLimited    = errors.New("cache: write rate limit exceeded")
+	ErrTTLTooShort    = errors.New("cache: expiry is below the minimum TTL")
+	ErrQueueFull      = errors.New("cache: read-only write queue is full")
+	ErrLoaderPanicked = errors.New("cache: loader panicked")
+)
+
+// keyError wraps err with the operation and key it applies to.
+func keyError(op, k string, err error) error {
+	return fmt.Errorf("%s %q: %w", op, k, err)
+}
@@ -0,0 +1,41 @@
+package main
+
+// defaultEventBuffer is the expiration channel size when
+// WithExpirationEvents is not given.
+const defaultEventBuffer = 1024
+
+// WithExpirationEvents sets the size of the channel returned by
+// ExpirationEvents. If block is true, removals wait for a slow consumer
+// instead of dropping events; the wait happens after the lock is released,
+// so only the goroutine that removed the item is held up.
+func WithExpirationEvents(buffer int, block bool) Option {
+	return func(c *Cache) {
+		c.expirations = make(chan string, buffer)
+		c.blockExpirations = block
+	}
+}
+
+// ExpirationEvents returns a channel that receives the key of every item
+// removed because it expired. Unless configured otherwise with
+// WithExpirationEvents, events are dropped when the channel is full.
+func (c *Cache) ExpirationEvents() <-chan string {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.expirations == nil {
+		c.expirations = make(chan string, defaultEventBuffer)
+	}
+	return c.expirations
+}
+
+// sendExpiration delivers an expiration event. It must be called without
+// the lock held.
+func (c *Cache) sendExpiration(ch chan string, key string) {
+	if c.blockExpirations {
+		ch <- key
+		return
+	}
+	select {
+	case ch <- key:
+	default:
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestExpirationEvents(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(time.Minute, WithClock(clock.Now))
+	events := c.ExpirationEvents()
+
+	c.Set("a", "1", time.Second)
+	c.Set("b", "2", time.Second)
+	c.Set("live", "3", time.Hour)
+	c.Delete("live") // deletions are not expirations
+	clock.Advance(time.Minute)
+	c.DeleteExpired()
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case k := <-events:
+			got = append(got, k)
+		case <-time.After(time.Second):
+			t.Fatalf("received %v, want events for a and b", got)
+		}
+	}
+	sort.Strings(got)
+	if got[0] != "a" || got[1] != "b" {
+		t.Fatalf("events = %v, want [a b]", got)
+	}
+	select {
+	case k := <-events:
+		t.Fatalf("unexpected event for %s", k)
+	default:
+	}
+}
+
+func TestExpirationEventsDropWhenFull(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(time.Minute, WithClock(clock.Now), WithExpirationEvents(1, false))
+	events := c.ExpirationEvents()
+
+	c.Set("a", "1", time.Second)
+	c.Set("b", "2", time.Second)
+	clock.Advance(time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		c.DeleteExpired()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("DeleteExpired blocked on a full event channel")
+	}
+	if n := len(events); n != 1 {
+		t.Fatalf("channel holds %d events, want 1", n)
+	}
+}
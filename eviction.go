@@ -0,0 +1,274 @@
+package main
+
+import "sync/atomic"
+
+// EvictionPolicy selects which live items are removed when the cache is full
+// and cleaning up expired items did not free enough room.
+type EvictionPolicy int
+
+const (
+	// EvictRandom removes arbitrary items. It is the default policy.
+	EvictRandom EvictionPolicy = iota
+	// EvictLRU removes the least recently used items first.
+	EvictLRU
+	// EvictLFU removes the least frequently read items first.
+	EvictLFU
+	// EvictLowestCost removes the items that are cheapest to rebuild first,
+	// as given to SetWithCost.
+	EvictLowestCost
+	// EvictSegmentedLRU splits the items into a cold and a hot segment; see
+	// WithSegmentedLRU.
+	EvictSegmentedLRU
+)
+
+// EvictionReason tells an eviction callback why an item was removed.
+type EvictionReason int
+
+const (
+	// ReasonExpired means the item's TTL passed.
+	ReasonExpired EvictionReason = iota
+	// ReasonCapacity means the item was evicted to make room for another.
+	ReasonCapacity
+	// ReasonDeleted means the item was removed explicitly.
+	ReasonDeleted
+	// ReasonIdle means the item was not used within the idle timeout.
+	ReasonIdle
+)
+
+type evictedItem struct {
+	it     *item
+	reason EvictionReason
+}
+
+// OnEvicted registers f to be called with the key and value of every item
+// removed from the cache by expiry, capacity eviction or Delete. Passing nil
+// removes the callback.
+func (c *Cache) OnEvicted(f func(key, value string)) {
+	if f == nil {
+		c.OnEvictedWithReason(nil)
+		return
+	}
+	c.OnEvictedWithReason(func(key, value string, _ EvictionReason) {
+		f(key, value)
+	})
+}
+
+// OnEvictedWithReason is like OnEvicted but also tells f why the item was
+// removed.
+func (c *Cache) OnEvictedWithReason(f func(key, value string, reason EvictionReason)) {
+	c.mu.Lock()
+	defer c.unlock()
+	c.onEvicted = f
+}
+
+// unlock releases the write lock and then saves the writes made while it was
+// held to the store, calls the eviction callback, sends expiration events
+// and logs evictions for the items removed, so none of them runs under the
+// lock.
+func (c *Cache) unlock() {
+	evicted, f, expirations := c.evicted, c.onEvicted, c.expirations
+	capacityEvicted, watchQueue, saves := c.capacityEvicted, c.watchQueue, c.saves
+	c.evicted, c.capacityEvicted, c.watchQueue, c.saves = nil, 0, nil, nil
+	c.mu.Unlock()
+
+	if len(saves) > 0 {
+		c.flushSaves(saves)
+	}
+
+	if len(watchQueue) > 0 {
+		c.sendWatchEvents(watchQueue)
+	}
+
+	if capacityEvicted > 0 && c.logger != nil {
+		c.logRated(&c.evictLog, "evict", capacityEvicted)
+	}
+
+	for _, e := range evicted {
+		if expirations != nil && e.reason == ReasonExpired {
+			c.sendExpiration(expirations, e.it.key)
+		}
+		if f == nil {
+			continue
+		}
+		v, err := c.decode(e.it.val, e.it.compressed)
+		if err != nil {
+			continue
+		}
+		f(e.it.key, v, e.reason)
+	}
+}
+
+// touchLocked records a read of it. The caller must hold at least the read
+// lock, and the write lock if readsMutate reports true.
+func (c *Cache) touchLocked(it *item) {
+	now := c.clock()
+	atomic.StoreInt64(&it.lastAccess, now.UnixNano())
+	switch c.policy {
+	case EvictLRU:
+		c.order.moveToFront(it)
+		c.touchQuotaLocked(it)
+	case EvictLFU:
+		atomic.AddUint64(&it.hits, 1)
+	case EvictSegmentedLRU:
+		c.promoteLocked(it)
+	}
+	if c.sliding && it.ttl > 0 {
+		c.setExpiry(it, now, it.ttl)
+	}
+}
+
+// readsMutate reports whether touchLocked modifies items, in which case
+// reads must take the write lock.
+func (c *Cache) readsMutate() bool {
+	return c.policy == EvictLRU || c.policy == EvictSegmentedLRU || c.sliding
+}
+
+// evictLocked removes live items according to the eviction policy until at
+// most n remain, or until the write has removed the eviction batch size,
+// counting the removed items the caller already took out for it. The caller
+// must hold the write lock.
+func (c *Cache) evictLocked(n, removed int) {
+	if n < 0 {
+		n = 0
+	}
+	if c.evictBatch > 0 {
+		budget := c.evictBatch - removed
+		if budget < 0 {
+			budget = 0
+		}
+		if len(c.items)-n > budget {
+			n = len(c.items) - budget
+		}
+	}
+	switch c.policy {
+	case EvictLRU, EvictSegmentedLRU:
+		for len(c.items) > n {
+			c.removeLocked(c.order.back().key, ReasonCapacity)
+		}
+		return
+	case EvictLFU:
+		for len(c.items) > n {
+			c.removeLocked(c.leastFrequentLocked().key, ReasonCapacity)
+		}
+		return
+	case EvictLowestCost:
+		for len(c.items) > n {
+			c.removeLocked(c.cheapestLocked().key, ReasonCapacity)
+		}
+		return
+	}
+
+	// Map iteration order is unspecified, so the evicted items are
+	// effectively random.
+	for k := range c.items {
+		if len(c.items) <= n {
+			return
+		}
+		c.removeLocked(k, ReasonCapacity)
+	}
+}
+
+// Resize changes the maximum number of items, zero meaning no limit. If the
+// cache holds more than maxItems, expired items are removed and then live
+// ones evicted according to the eviction policy until it fits.
+func (c *Cache) Resize(maxItems int) {
+	c.mu.Lock()
+	defer c.unlock()
+	c.maxItems = maxItems
+	if maxItems > 0 && len(c.items) > maxItems {
+		c.evictLocked(maxItems, c.cleanupLocked())
+	}
+}
+
+// WithEvictionBatch limits each write, and Resize, to removing at most n
+// items, expired ones included. A cache far over its limit, such as after
+// Resize, then shrinks a little with every write instead of stalling one of
+// them. n is raised to 2 if lower, the least that still shrinks the cache
+// while every write adds an item. Zero means no limit.
+func WithEvictionBatch(n int) Option {
+	return func(c *Cache) {
+		if n > 0 && n < 2 {
+			n = 2
+		}
+		c.evictBatch = n
+	}
+}
+
+// shrinkLocked evicts items from the back of the list until the stored
+// values fit in maxBytes, then according to the eviction policy until their
+// cost fits in maxCost. The caller must hold the write lock.
+func (c *Cache) shrinkLocked() {
+	for c.maxBytes > 0 && c.bytes > c.maxBytes && c.order.back() != nil {
+		c.removeLocked(c.order.back().key, ReasonCapacity)
+	}
+	for c.maxCost > 0 && c.cost > c.maxCost && len(c.items) > 0 {
+		c.removeLocked(c.victimLocked().key, ReasonCapacity)
+	}
+}
+
+// leastFrequentLocked returns the item with the fewest reads, preferring the
+// older one on a tie. The caller must hold the write lock.
+func (c *Cache) leastFrequentLocked() *item {
+	least := c.order.back()
+	for it := least; it != nil; it = it.prev {
+		if it.hits < least.hits {
+			least = it
+		}
+	}
+	return least
+}
+
+// itemList is an intrusive doubly linked list of items, most recently used
+// at the front. All methods must be called with the write lock held.
+type itemList struct {
+	head, tail *item
+}
+
+func (l *itemList) pushFront(it *item) {
+	it.prev = nil
+	it.next = l.head
+	if l.head != nil {
+		l.head.prev = it
+	}
+	l.head = it
+	if l.tail == nil {
+		l.tail = it
+	}
+}
+
+func (l *itemList) remove(it *item) {
+	if it.prev != nil {
+		it.prev.next = it.next
+	} else {
+		l.head = it.next
+	}
+	if it.next != nil {
+		it.next.prev = it.prev
+	} else {
+		l.tail = it.prev
+	}
+	it.prev, it.next = nil, nil
+}
+
+func (l *itemList) insertAfter(mark, it *item) {
+	it.prev = mark
+	it.next = mark.next
+	if mark.next != nil {
+		mark.next.prev = it
+	} else {
+		l.tail = it
+	}
+	mark.next = it
+}
+
+func (l *itemList) moveToFront(it *item) {
+	if l.head == it {
+		return
+	}
+	l.remove(it)
+	l.pushFront(it)
+}
+
+func (l *itemList) back() *item {
+	return l.tail
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCacheWithJanitor(time.Minute, 3, WithEvictionPolicy(EvictLRU))
+	defer c.Close()
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", time.Hour)
+	c.Set("c", "3", time.Hour)
+
+	// Reading a and c leaves b as the least recently used item.
+	c.Get("a")
+	c.Get("c")
+	c.Set("d", "4", time.Hour)
+
+	if c.Has("b") {
+		t.Fatal("least recently used key b survived eviction")
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if !c.Has(k) {
+			t.Fatalf("key %s was evicted, want b evicted", k)
+		}
+	}
+
+	// Overwriting a counts as a use, so c is evicted next.
+	c.Set("a", "5", time.Hour)
+	c.Set("e", "6", time.Hour)
+	if c.Has("c") {
+		t.Fatal("key c survived eviction after a was overwritten")
+	}
+}
+
+func TestLRUListTracksMap(t *testing.T) {
+	c := NewCacheWithJanitor(time.Minute, 10, WithEvictionPolicy(EvictLRU))
+	defer c.Close()
+	for i := 0; i < 50; i++ {
+		k := fmt.Sprintf("%d", i%15)
+		c.Set(k, k, time.Hour)
+		if i%4 == 0 {
+			c.Delete(fmt.Sprintf("%d", i%7))
+		}
+	}
+
+	n := 0
+	for it := c.order.head; it != nil; it = it.next {
+		if c.items[it.key] != it {
+			t.Fatalf("list item %q is not the item in the map", it.key)
+		}
+		n++
+	}
+	if n != len(c.items) {
+		t.Fatalf("list holds %d items, map holds %d", n, len(c.items))
+	}
+}
+
+func TestLFUEvictsLeastFrequentlyUsed(t *testing.T) {
+	maxItems := 5
+	c := NewCacheWithJanitor(time.Minute, maxItems, WithEvictionPolicy(EvictLFU))
+	defer c.Close()
+	c.Set("hot", "h", time.Hour)
+	for i := 0; i < maxItems-1; i++ {
+		c.Set(fmt.Sprintf("cold%d", i), "c", time.Hour)
+	}
+
+	for i := 0; i < 100; i++ {
+		c.Get("hot")
+	}
+	for i := 1; i < maxItems-1; i++ {
+		c.Get(fmt.Sprintf("cold%d", i))
+	}
+
+	// cold0 was never read, so it must go first.
+	c.Set("new", "n", time.Hour)
+	if c.Has("cold0") {
+		t.Fatal("never-read key cold0 survived eviction")
+	}
+
+	for i := 0; i < 20; i++ {
+		c.Set(fmt.Sprintf("flood%d", i), "f", time.Hour)
+		if !c.Has("hot") {
+			t.Fatalf("hot key evicted after %d inserts", i+1)
+		}
+	}
+}
+
+func TestOnEvicted(t *testing.T) {
+	c := NewCacheWithJanitor(5*time.Millisecond, 3)
+	defer c.Close()
+
+	var mu sync.Mutex
+	counts := map[EvictionReason]int{}
+	c.OnEvictedWithReason(func(key, value string, reason EvictionReason) {
+		mu.Lock()
+		counts[reason]++
+		mu.Unlock()
+		// The callback runs outside the lock, so it may use the cache.
+		c.Has(key)
+	})
+
+	c.Set("a", "1", time.Hour)
+	c.Delete("a")
+
+	c.Set("b", "1", time.Hour)
+	c.Set("c", "1", time.Hour)
+	c.Set("d", "1", time.Hour)
+	c.Set("e", "1", time.Hour)
+
+	c.Set("short", "1", time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		expired := counts[ReasonExpired]
+		mu.Unlock()
+		if expired > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("janitor did not report the expired item")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if counts[ReasonDeleted] != 1 {
+		t.Errorf("deleted callbacks = %d, want 1", counts[ReasonDeleted])
+	}
+	if counts[ReasonCapacity] != 2 {
+		t.Errorf("capacity callbacks = %d, want 2", counts[ReasonCapacity])
+	}
+	if counts[ReasonExpired] != 1 {
+		t.Errorf("expired callbacks = %d, want 1", counts[ReasonExpired])
+	}
+}
+
+func TestOnEvictedValue(t *testing.T) {
+	c := NewCache(time.Minute)
+	var gotKey, gotValue string
+	c.OnEvicted(func(key, value string) {
+		gotKey, gotValue = key, value
+	})
+
+	c.Set("a", "1", time.Hour)
+	c.Delete("a")
+	if gotKey != "a" || gotValue != "1" {
+		t.Fatalf("callback got %q, %q; want \"a\", \"1\"", gotKey, gotValue)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	maxBytes := int64(10 * 1024)
+	c := NewCache(time.Minute, WithMaxBytes(maxBytes))
+
+	for i := 0; i < 200; i++ {
+		size := 10
+		if i%10 == 0 {
+			size = 3000
+		}
+		c.Set(fmt.Sprintf("%d", i), strings.Repeat("x", size), time.Hour)
+		if c.bytes > maxBytes {
+			t.Fatalf("after %d writes the cache holds %d bytes, want at most %d", i+1, c.bytes, maxBytes)
+		}
+	}
+
+	var total int64
+	for _, it := range c.items {
+		total += int64(len(it.val))
+	}
+	if total != c.bytes {
+		t.Fatalf("tracked %d bytes, items hold %d", c.bytes, total)
+	}
+
+	c.Delete("199")
+	c.Flush()
+	if c.bytes != 0 {
+		t.Fatalf("tracked %d bytes after Flush, want 0", c.bytes)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	clock := newFakeClock()
+	c := NewCache(time.Minute, WithClock(clock.Now), WithIdleTimeout(10*time.Minute))
+	c.Set("idle", "1", time.Hour)
+	c.Set("busy", "2", time.Hour)
+
+	for i := 0; i < 4; i++ {
+		clock.Advance(5 * time.Minute)
+		c.Get("busy")
+		c.DeleteExpired()
+	}
+
+	if c.Has("idle") {
+		t.Fatal("idle item survived past the idle timeout")
+	}
+	if !c.Has("busy") {
+		t.Fatal("frequently read item was evicted as idle")
+	}
+}
+
+func TestResize(t *testing.T) {
+	c := New(WithMaxItems(5), WithEvictionPolicy(EvictLRU))
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		c.Set(k, k, time.Hour)
+	}
+	// Reading a and b makes c, d and e the least recently used.
+	c.Get("a")
+	c.Get("b")
+
+	c.Resize(2)
+	if n := c.Size(); n != 2 {
+		t.Fatalf("Size after Resize(2) = %d, want 2", n)
+	}
+	for _, k := range []string{"a", "b"} {
+		if !c.Has(k) {
+			t.Errorf("recently used key %s was evicted", k)
+		}
+	}
+
+	// The new limit applies to later writes too.
+	c.Set("f", "f", time.Hour)
+	if n := c.Size(); n != 2 || c.Has("a") {
+		t.Fatalf("Size = %d, Has(a) = %v after a write; want 2, false", n, c.Has("a"))
+	}
+
+	c.Resize(0)
+	for _, k := range []string{"g", "h", "i"} {
+		c.Set(k, k, time.Hour)
+	}
+	if n := c.Size(); n != 5 {
+		t.Fatalf("Size after Resize(0) = %d, want 5", n)
+	}
+}
+
+func TestEvictionBatch(t *testing.T) {
+	c := New(WithMaxItems(1000), WithEvictionPolicy(EvictLRU), WithEvictionBatch(10))
+	evicted := 0
+	c.OnEvicted(func(key, value string) { evicted++ })
+	for i := 0; i < 1000; i++ {
+		c.Set(strconv.Itoa(i), "v", time.Hour)
+	}
+
+	c.Resize(100)
+	if evicted != 10 {
+		t.Fatalf("Resize evicted %d items, want the batch of 10", evicted)
+	}
+	writes := 0
+	for c.Size() > 100 {
+		evicted = 0
+		c.Set("new"+strconv.Itoa(writes), "v", time.Hour)
+		if evicted > 10 {
+			t.Fatalf("a Set evicted %d items, want at most 10", evicted)
+		}
+		if writes++; writes > 200 {
+			t.Fatalf("cache did not shrink to its limit: %d items after %d writes", c.Size(), writes)
+		}
+	}
+	// Each write adds one item and removes ten, so 890 extra items take 99.
+	if writes != 99 {
+		t.Fatalf("converged after %d writes, want 99", writes)
+	}
+}
+
+func TestEvictionBatchExpired(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithMaxItems(10000), WithEvictionBatch(10))
+	removed := 0
+	c.OnEvicted(func(key, value string) { removed++ })
+	for i := 0; i < 10000; i++ {
+		c.Set(strconv.Itoa(i), "v", time.Second)
+	}
+	clock.Advance(time.Minute)
+
+	// The cache is full of expired items, all due for removal at once.
+	c.Set("new", "v", time.Hour)
+	if removed != 10 {
+		t.Fatalf("a Set removed %d expired items, want the batch of 10", removed)
+	}
+	removed = 0
+	c.Resize(100)
+	if removed != 10 {
+		t.Fatalf("Resize removed %d expired items, want the batch of 10", removed)
+	}
+}
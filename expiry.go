@@ -0,0 +1,76 @@
+package main
+
+import "container/heap"
+
+// expiryHeap is a min-heap of the items that can expire, ordered by expiry,
+// so a sweep only visits the items that have actually expired. All methods
+// must be called with the write lock held.
+type expiryHeap []*item
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiry < h[j].expiry }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIndex = i
+	h[j].heapIndex = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	it := x.(*item)
+	it.heapIndex = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	it.heapIndex = -1
+	*h = old[:n-1]
+	return it
+}
+
+// update moves it to its place after its expiry changed, adding or removing
+// it as it starts or stops expiring.
+func (h *expiryHeap) update(it *item) {
+	switch {
+	case it.expiry == 0:
+		h.remove(it)
+	case it.heapIndex < 0:
+		heap.Push(h, it)
+	default:
+		heap.Fix(h, it.heapIndex)
+	}
+}
+
+func (h *expiryHeap) remove(it *item) {
+	if it.heapIndex >= 0 {
+		heap.Remove(h, it.heapIndex)
+	}
+}
+
+// peek returns the item that expires first, or nil.
+func (h expiryHeap) peek() *item {
+	if len(h) == 0 {
+		return nil
+	}
+	return h[0]
+}
+
+// expiredAt returns the items expired at now, visiting only them and their
+// children. It only reads the heap, so the read lock is enough.
+func (h expiryHeap) expiredAt(now int64) []*item {
+	var due []*item
+	for next := []int{0}; len(next) > 0; {
+		i := next[len(next)-1]
+		next = next[:len(next)-1]
+		if i >= len(h) || !h[i].expired(now) {
+			continue
+		}
+		due = append(due, h[i])
+		next = append(next, 2*i+1, 2*i+2)
+	}
+	return due
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpiryHeapNeverReapsLiveItems(t *testing.T) {
+	c := NewCache(time.Minute)
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		k := strconv.Itoa(rnd.Intn(300))
+		switch rnd.Intn(4) {
+		case 0:
+			c.Delete(k)
+		case 1:
+			c.Touch(k, time.Duration(rnd.Intn(3))*time.Hour)
+		default:
+			ttl := time.Hour
+			if rnd.Intn(3) == 0 {
+				ttl = time.Millisecond
+			} else if rnd.Intn(5) == 0 {
+				ttl = NoExpiration
+			}
+			c.Set(k, k, ttl)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	live := map[string]bool{}
+	now := time.Now().UnixNano()
+	for k, it := range c.items {
+		if !it.expired(now) {
+			live[k] = true
+		}
+	}
+
+	c.DeleteExpired()
+	for k := range live {
+		if !c.Has(k) {
+			t.Fatalf("live key %s was reaped", k)
+		}
+	}
+	if n := c.ItemCount(); n != len(live) {
+		t.Fatalf("cache holds %d items after the sweep, want %d", n, len(live))
+	}
+
+	for i, it := range c.expiries {
+		if it.heapIndex != i {
+			t.Fatalf("item %s has heap index %d, want %d", it.key, it.heapIndex, i)
+		}
+		if c.items[it.key] != it {
+			t.Fatalf("heap holds item %s that is not in the map", it.key)
+		}
+	}
+}
+
+func BenchmarkSweepHeap(b *testing.B) {
+	benchmarkSweep(b, func(c *Cache) { c.DeleteExpired() })
+}
+
+// BenchmarkSweepFullScan measures the map scan the heap replaced.
+func BenchmarkSweepFullScan(b *testing.B) {
+	benchmarkSweep(b, func(c *Cache) {
+		c.mu.Lock()
+		defer c.unlock()
+		now := time.Now().UnixNano()
+		for k, it := range c.items {
+			if it.expired(now) {
+				c.removeLocked(k, ReasonExpired)
+			}
+		}
+	})
+}
+
+func benchmarkSweep(b *testing.B, sweep func(*Cache)) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		c := NewCache(time.Minute)
+		for j := 0; j < 100000; j++ {
+			c.Set(strconv.Itoa(j), "v", time.Hour)
+		}
+		for j := 0; j < 100; j++ {
+			c.Set("expired"+strconv.Itoa(j), "v", time.Nanosecond)
+		}
+		time.Sleep(time.Millisecond)
+		b.StartTimer()
+
+		sweep(c)
+	}
+}
+
+func TestExpiryJitter(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithExpiryJitter(0.1))
+	plain := New(WithClock(clock.Now))
+	for i := 0; i < 1000; i++ {
+		k := strconv.Itoa(i)
+		c.Set(k, "v", time.Hour)
+		plain.Set(k, "v", time.Hour)
+	}
+
+	nominal := clock.Now().Add(time.Hour).UnixNano()
+	spread := int64(6 * time.Minute)
+	min, max := nominal, nominal
+	distinct := make(map[int64]bool)
+	for _, it := range c.items {
+		if it.expiry < nominal-spread || it.expiry > nominal+spread {
+			t.Fatalf("expiry %v from the nominal one is outside ±10%%", time.Duration(it.expiry-nominal))
+		}
+		if it.expiry < min {
+			min = it.expiry
+		}
+		if it.expiry > max {
+			max = it.expiry
+		}
+		distinct[it.expiry] = true
+	}
+	if len(distinct) < 900 || time.Duration(max-min) < 10*time.Minute {
+		t.Fatalf("%d distinct expiries spread over %v, want them spread over most of ±6m",
+			len(distinct), time.Duration(max-min))
+	}
+	for k, it := range plain.items {
+		if it.expiry != nominal {
+			t.Fatalf("%s expires %v off the nominal time without jitter", k, time.Duration(it.expiry-nominal))
+		}
+	}
+	if it := c.items["0"]; it.ttl != time.Hour {
+		t.Fatalf("ttl = %v, want the requested 1h", it.ttl)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// hotSample makes a tracked or rising key take the hot-keys lock on only
+// one read in hotSample, as its count passes a multiple of it.
+const hotSample = 16
+
+// KeyCount is a key with its approximate number of reads.
+type KeyCount struct {
+	Key   string
+	Count uint64
+}
+
+// WithHotKeys makes the cache track roughly which keys are read the most,
+// keeping the top capacity of them for HotKeys. Reads are counted in a
+// count-min sketch, so every Get pays for a few atomic increments; only
+// about one read in hotSample of a key that could be in the top takes a
+// lock, for a heap update of O(log capacity).
+func WithHotKeys(capacity int) Option {
+	return func(c *Cache) {
+		if capacity <= 0 {
+			c.hot = nil
+			return
+		}
+		c.hot = &hotKeys{
+			sketch:   newCountMinSketch(capacity),
+			capacity: capacity,
+			byKey:    make(map[string]*hotEntry, capacity),
+		}
+	}
+}
+
+// HotKeys returns up to n of the most read keys, most read first, with their
+// approximate read counts. Counts fade over time as the sketch ages. It
+// returns nil unless WithHotKeys is set.
+func (c *Cache) HotKeys(n int) []KeyCount {
+	if c.hot == nil || n <= 0 {
+		return nil
+	}
+	return c.hot.topN(n)
+}
+
+// hotKeys tracks the most read keys: the sketch counts every read and top
+// is a min-heap of the keys with the highest counts seen.
+type hotKeys struct {
+	sketch   *countMinSketch
+	capacity int
+	floor    uint32 // count at the root of a full heap, read without mu
+	gen      uint64 // sketch generation the counts in top belong to
+
+	mu    sync.Mutex
+	top   hotHeap
+	byKey map[string]*hotEntry
+}
+
+type hotEntry struct {
+	key   string
+	count uint32
+	index int
+}
+
+// record counts a read of k.
+func (h *hotKeys) record(k string) {
+	h.sketch.increment(k)
+	n := h.sketch.estimate(k)
+	gen := h.sketch.generation()
+	if floor := atomic.LoadUint32(&h.floor); floor > 0 && (n <= floor || n%hotSample != 0) &&
+		gen == atomic.LoadUint64(&h.gen) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ageLocked(gen)
+	if e, ok := h.byKey[k]; ok {
+		e.count = n
+		heap.Fix(&h.top, e.index)
+	} else if len(h.top) < h.capacity {
+		e := &hotEntry{key: k, count: n}
+		heap.Push(&h.top, e)
+		h.byKey[k] = e
+	} else if root := h.top[0]; n > root.count {
+		delete(h.byKey, root.key)
+		root.key, root.count = k, n
+		h.byKey[k] = root
+		heap.Fix(&h.top, 0)
+	}
+	if len(h.top) == h.capacity {
+		atomic.StoreUint32(&h.floor, h.top[0].count)
+	}
+}
+
+// ageLocked halves the tracked counts once for every time the sketch has
+// been halved since they were recorded, so the floor keeps up with the
+// sketch. Halving keeps the heap order. The caller must hold mu.
+func (h *hotKeys) ageLocked(gen uint64) {
+	old := atomic.LoadUint64(&h.gen)
+	if gen <= old {
+		return
+	}
+	shift := gen - old
+	if shift > 32 {
+		shift = 32
+	}
+	for _, e := range h.top {
+		e.count = uint32(uint64(e.count) >> shift)
+	}
+	atomic.StoreUint64(&h.gen, gen)
+	if len(h.top) == h.capacity {
+		atomic.StoreUint32(&h.floor, h.top[0].count)
+	}
+}
+
+func (h *hotKeys) topN(n int) []KeyCount {
+	h.mu.Lock()
+	keys := make([]KeyCount, 0, len(h.top))
+	for _, e := range h.top {
+		// Re-estimate, since the sketch may have aged since e was seen.
+		keys = append(keys, KeyCount{e.key, uint64(h.sketch.estimate(e.key))})
+	}
+	h.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// hotHeap is a min-heap of tracked keys by count.
+type hotHeap []*hotEntry
+
+func (h hotHeap) Len() int           { return len(h) }
+func (h hotHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h hotHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *hotHeap) Push(x interface{}) {
+	e := x.(*hotEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *hotHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHotKeys(t *testing.T) {
+	c := New(WithHotKeys(20))
+	for i := 0; i < 500; i++ {
+		c.Set(strconv.Itoa(i), "v", time.Hour)
+	}
+
+	// Three keys take most of the reads; the rest are read about once each.
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		switch r := rnd.Intn(10); {
+		case r < 4:
+			c.Get("7")
+		case r < 6:
+			c.Get("42")
+		case r < 7:
+			c.Get("300")
+		default:
+			c.Get(strconv.Itoa(rnd.Intn(500)))
+		}
+	}
+
+	top := c.HotKeys(3)
+	if len(top) != 3 {
+		t.Fatalf("HotKeys(3) = %v, want 3 keys", top)
+	}
+	for i, want := range []string{"7", "42", "300"} {
+		if top[i].Key != want {
+			t.Fatalf("HotKeys(3) = %v, want 7, 42, 300 in that order", top)
+		}
+	}
+	if top[0].Count < 2000 {
+		t.Fatalf("count for 7 = %d, want about 2000", top[0].Count)
+	}
+	if n := len(c.HotKeys(100)); n != 20 {
+		t.Fatalf("HotKeys(100) returned %d keys, want the 20 tracked", n)
+	}
+}
+
+func TestHotKeysOffByDefault(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", 0)
+	c.Get("a")
+	if keys := c.HotKeys(1); keys != nil {
+		t.Fatalf("HotKeys without WithHotKeys = %v, want nil", keys)
+	}
+}
+
+func TestHotKeysAfterAging(t *testing.T) {
+	c := New(WithHotKeys(5))
+	old := []string{"a", "b", "c", "d", "e"}
+	for i := 0; i < 2000; i++ {
+		for _, k := range old {
+			c.Get(k)
+		}
+	}
+	// The sketch halves partway through, so "new" overtakes the old keys'
+	// halved counts without reaching their earlier ones.
+	for i := 0; i < 1500; i++ {
+		c.Get("new")
+	}
+
+	for _, kc := range c.HotKeys(5) {
+		if kc.Key == "new" {
+			return
+		}
+	}
+	t.Fatalf("HotKeys(5) = %v, want the newly hot key among them", c.HotKeys(5))
+}
+
+func BenchmarkGetParallelHotKeys(b *testing.B) {
+	c := New(WithHotKeys(100))
+	for i := 0; i < 1000; i++ {
+		c.Set(strconv.Itoa(i), "v", time.Hour)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		rnd := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			c.Get(strconv.Itoa(rnd.Intn(1000)))
+		}
+	})
+}
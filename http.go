@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// statsPath is the route HTTPHandler serves Stats on. It cannot be used as a
+// key over HTTP.
+const statsPath = "/_stats"
+
+// HTTPHandler returns a handler exposing the cache over HTTP:
+//
+//	GET    /key     returns the value, or 404 if it is missing or expired
+//	PUT    /key     stores the request body, with the expiry taken from the
+//	                X-TTL header or the ttl query parameter, e.g. "90s"
+//	DELETE /key     removes the key
+//	GET    /_stats  returns Stats as JSON
+//
+// Writes are rejected with 409 Conflict while the cache is read-only (503
+// Service Unavailable once a WithReadOnlyQueue queue is full) and 429 Too
+// Many Requests over the write rate limit. A PUT with an expiry below the
+// WithMinTTL minimum is rejected with 400 Bad Request, and a body over the
+// WithMaxValueSize limit with 413 Request Entity Too Large before the rest
+// of it is read.
+func (c *Cache) HTTPHandler() http.Handler {
+	return http.HandlerFunc(c.serveHTTP)
+}
+
+func (c *Cache) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == statsPath {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.Stats())
+		return
+	}
+
+	k := strings.TrimPrefix(r.URL.Path, "/")
+	if k == "" {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		v, ok := c.Get(k)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, v)
+	case http.MethodPut:
+		expiry, err := requestTTL(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if c.maxValueSize > 0 {
+			// One byte over the limit is enough to tell the value is too
+			// large, without reading the rest of the body.
+			r.Body = http.MaxBytesReader(w, r.Body, int64(c.maxValueSize)+1)
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil && c.maxValueSize > 0 && len(body) > c.maxValueSize {
+			err = keyError("set", k, ErrValueTooLarge)
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := c.TrySet(k, string(body), expiry); err != nil {
+			http.Error(w, err.Error(), writeErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if c.isReadOnly() {
+			http.Error(w, ErrReadOnly.Error(), http.StatusConflict)
+			return
+		}
+		c.Delete(k)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// requestTTL returns the expiry requested by a PUT, DefaultExpiration if
+// none is given.
+func requestTTL(r *http.Request) (time.Duration, error) {
+	s := r.Header.Get("X-TTL")
+	if s == "" {
+		s = r.URL.Query().Get("ttl")
+	}
+	if s == "" {
+		return DefaultExpiration, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// writeErrorStatus maps a TrySet error to an HTTP status.
+func writeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrReadOnly):
+		return http.StatusConflict
+	case errors.Is(err, ErrValueTooLarge), errors.Is(err, ErrCapacity):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrTTLTooShort):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrQueueFull):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(h http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHTTPHandler(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithMaxValueSize(8))
+	h := c.HTTPHandler()
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		header   []string
+		wantCode int
+		wantBody string
+	}{
+		{"put", http.MethodPut, "/a", "1", nil, http.StatusNoContent, ""},
+		{"get", http.MethodGet, "/a", "", nil, http.StatusOK, "1"},
+		{"get missing", http.MethodGet, "/missing", "", nil, http.StatusNotFound, ""},
+		{"put ttl header", http.MethodPut, "/h", "2", []string{"X-TTL", "1s"}, http.StatusNoContent, ""},
+		{"put ttl query", http.MethodPut, "/q?ttl=1s", "3", nil, http.StatusNoContent, ""},
+		{"put bad ttl", http.MethodPut, "/b?ttl=soon", "4", nil, http.StatusBadRequest, ""},
+		{"put too large", http.MethodPut, "/big", "0123456789", nil, http.StatusRequestEntityTooLarge, ""},
+		{"delete", http.MethodDelete, "/a", "", nil, http.StatusNoContent, ""},
+		{"get deleted", http.MethodGet, "/a", "", nil, http.StatusNotFound, ""},
+		{"no key", http.MethodGet, "/", "", nil, http.StatusBadRequest, ""},
+		{"bad method", http.MethodPost, "/a", "", nil, http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		w := serve(h, tt.method, tt.target, tt.body, tt.header...)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantCode)
+		}
+		if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+			t.Errorf("%s: body = %q, want %q", tt.name, w.Body.String(), tt.wantBody)
+		}
+	}
+
+	for _, k := range []string{"h", "q"} {
+		if ttl, ok := c.TTL(k); !ok || ttl != time.Second {
+			t.Errorf("TTL(%s) = %v, %v; want 1s, true", k, ttl, ok)
+		}
+	}
+	clock.Advance(2 * time.Second)
+	if w := serve(h, http.MethodGet, "/h", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get expired: status = %d, want 404", w.Code)
+	}
+}
+
+func TestHTTPHandlerReadOnly(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", 0)
+	c.SaveAndExit("")
+	h := c.HTTPHandler()
+
+	if w := serve(h, http.MethodPut, "/a", "2"); w.Code != http.StatusConflict {
+		t.Errorf("put: status = %d, want 409", w.Code)
+	}
+	if w := serve(h, http.MethodDelete, "/a", ""); w.Code != http.StatusConflict {
+		t.Errorf("delete: status = %d, want 409", w.Code)
+	}
+	if w := serve(h, http.MethodGet, "/a", ""); w.Code != http.StatusOK || w.Body.String() != "1" {
+		t.Errorf("get: %d %q, want 200 \"1\"", w.Code, w.Body.String())
+	}
+}
+
+func TestHTTPHandlerWriteErrors(t *testing.T) {
+	clock := newFakeClock()
+	limited := New(WithClock(clock.Now), WithWriteRateLimit(1))
+	limited.Set("spent", "v", 0)
+	minTTL := New(WithMinTTL(time.Second))
+	queued := New(WithReadOnlyQueue(1))
+	queued.SaveAndExit("")
+	queued.Set("first", "v", 0)
+
+	tests := []struct {
+		name     string
+		c        *Cache
+		target   string
+		wantCode int
+	}{
+		{"rate limited", limited, "/a", http.StatusTooManyRequests},
+		{"below the minimum TTL", minTTL, "/c?ttl=1ms", http.StatusBadRequest},
+		{"queue full", queued, "/a", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		if w := serve(tt.c.HTTPHandler(), http.MethodPut, tt.target, "v"); w.Code != tt.wantCode {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantCode)
+		}
+	}
+}
+
+func TestHTTPHandlerStats(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", 0)
+	c.Get("a")
+	c.Get("missing")
+
+	w := serve(c.HTTPHandler(), http.MethodGet, "/_stats", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var got Stats
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding stats: %v", err)
+	}
+	if got.Hits != 1 || got.Misses != 1 || got.Items != 1 || got.MaxTTL <= 0 || got.MaxTTL > time.Minute {
+		t.Fatalf("stats = %+v, want 1 hit, 1 miss and 1 item with up to 1m left", got)
+	}
+}
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestHTTPHandlerLargeBody(t *testing.T) {
+	c := New(WithMaxValueSize(8))
+	body := &countingReader{r: strings.NewReader(strings.Repeat("x", 1<<20))}
+	r := httptest.NewRequest(http.MethodPut, "/big", body)
+	w := httptest.NewRecorder()
+	c.HTTPHandler().ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", w.Code)
+	}
+	if body.n >= 1<<20 {
+		t.Fatal("the handler read the whole oversized body")
+	}
+	if c.Has("big") {
+		t.Fatal("an oversized body was stored")
+	}
+}
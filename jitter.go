@@ -0,0 +1,32 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WithExpiryJitter spreads out the expiry of items written with the same
+// TTL by moving each one randomly by up to ±fraction of its TTL, so keys
+// warmed together do not all expire and reload at once. fraction is clamped
+// to [0, 1]. TTL still reports the jittered time left, while refreshes such
+// as sliding expiration start again from the requested TTL.
+func WithExpiryJitter(fraction float64) Option {
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	return func(c *Cache) {
+		c.jitter = fraction
+		c.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+}
+
+// jittered returns d moved randomly by up to the jitter fraction. The caller
+// must hold the write lock, which guards the cache's random source.
+func (c *Cache) jittered(d time.Duration) time.Duration {
+	if c.jitter == 0 {
+		return d
+	}
+	return d + time.Duration((c.rng.Float64()*2-1)*c.jitter*float64(d))
+}
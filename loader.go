@@ -0,0 +1,223 @@
+package main
+
+import "time"
+
+// call is an in-flight load shared by every caller waiting on the same key.
+type call struct {
+	done chan struct{}
+	val  string
+	err  error
+}
+
+// GetOrLoad returns the value stored under k. On a miss it calls loader,
+// stores the result with the given expiry and returns it. No lock is held
+// while loader runs. A loader error is returned as is and nothing is stored.
+//
+// With WithWriteThrough the store is tried before the loader, and a value
+// found there is cached without being saved again.
+//
+// Concurrent misses on the same key share a single loader call. If loader
+// panics, the panic reaches the caller that ran it and the callers sharing
+// the call get ErrLoaderPanicked. A key with a live SetMissing tombstone is
+// not loaded; GetOrLoad returns ErrNotFound. With WithErrorCaching, a loader
+// error is returned again without calling loader until it expires.
+func (c *Cache) GetOrLoad(k string, expiry time.Duration, loader func() (string, error)) (string, error) {
+	if v, ok := c.Get(k); ok {
+		return v, nil
+	}
+	if c.isMissing(k) {
+		return "", keyError("load", k, ErrNotFound)
+	}
+	if err := c.loadError(k); err != nil {
+		return "", err
+	}
+
+	return c.singleflight(k, func() (string, error) {
+		// An earlier load may have finished between the miss above and
+		// this call taking the in-flight slot.
+		if v, ok := c.Get(k); ok {
+			return v, nil
+		}
+		if err := c.loadError(k); err != nil {
+			return "", err
+		}
+
+		if c.store != nil {
+			v, ok, err := c.store.Load(k)
+			if err != nil {
+				return "", keyError("load", k, err)
+			}
+			if ok {
+				c.set(k, v, expiry, c.lockWrite)
+				return v, nil
+			}
+		}
+
+		v, err := loader()
+		if err != nil {
+			c.cacheLoadError(k, err)
+			return "", err
+		}
+		c.Set(k, v, expiry)
+		return v, nil
+	})
+}
+
+// singleflight runs fn for k unless a call for k is already in flight, in
+// which case it waits for that call and returns its result.
+func (c *Cache) singleflight(k string, fn func() (string, error)) (string, error) {
+	c.loadMu.Lock()
+	if cl, ok := c.loads[k]; ok {
+		c.loadMu.Unlock()
+		<-cl.done
+		return cl.val, cl.err
+	}
+	cl := &call{done: make(chan struct{})}
+	c.loads[k] = cl
+	c.loadMu.Unlock()
+
+	// If fn panics, its waiters get ErrLoaderPanicked and the slot is still
+	// freed, so later calls can load k again.
+	returned := false
+	defer func() {
+		if !returned {
+			cl.val, cl.err = "", keyError("load", k, ErrLoaderPanicked)
+		}
+		c.finishLoad(k, cl)
+	}()
+	cl.val, cl.err = fn()
+	returned = true
+	return cl.val, cl.err
+}
+
+// finishLoad releases the in-flight slot for k and wakes its waiters.
+func (c *Cache) finishLoad(k string, cl *call) {
+	c.loadMu.Lock()
+	delete(c.loads, k)
+	c.loadMu.Unlock()
+	close(cl.done)
+}
+
+// WithErrorCaching makes GetOrLoad remember a loader error for ttl and
+// return it for the key without calling the loader again, so a failing
+// backend is not retried on every call. Zero, the default, caches nothing.
+func WithErrorCaching(ttl time.Duration) Option {
+	return func(c *Cache) {
+		c.errorTTL = ttl
+	}
+}
+
+// loadErr is a cached loader error.
+type loadErr struct {
+	err    error
+	expiry int64
+}
+
+// loadError returns the cached loader error for k, if a live one exists.
+func (c *Cache) loadError(k string) error {
+	if c.errorTTL <= 0 {
+		return nil
+	}
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	e, ok := c.loadErrs[k]
+	if !ok {
+		return nil
+	}
+	if c.clock().UnixNano() >= e.expiry {
+		delete(c.loadErrs, k)
+		return nil
+	}
+	return e.err
+}
+
+// cacheLoadError remembers err as the result of loading k.
+func (c *Cache) cacheLoadError(k string, err error) {
+	if c.errorTTL <= 0 {
+		return
+	}
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if c.loadErrs == nil {
+		c.loadErrs = make(map[string]loadErr)
+	}
+	c.loadErrs[k] = loadErr{err, c.clock().Add(c.errorTTL).UnixNano()}
+}
+
+// removeLoadErrors drops the cached loader errors expired at now.
+func (c *Cache) removeLoadErrors(now int64) {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	for k, e := range c.loadErrs {
+		if now >= e.expiry {
+			delete(c.loadErrs, k)
+		}
+	}
+}
+
+// WithRefreshAhead makes a read of an item expiring within window reload it
+// in the background with loader, so hot keys are replaced before they
+// expire. The read still returns the current value. The reloaded value keeps
+// the item's lifetime, and a loader error leaves the item as it is.
+func WithRefreshAhead(window time.Duration, loader func(key string) (string, error)) Option {
+	return func(c *Cache) {
+		c.refreshWindow = window
+		c.refreshLoader = loader
+	}
+}
+
+// refreshAhead starts a background reload of k if it expires within the
+// refresh window.
+func (c *Cache) refreshAhead(k string, expiry int64, ttl time.Duration) {
+	if c.refreshLoader == nil || expiry == 0 ||
+		expiry-c.clock().UnixNano() > int64(c.refreshWindow) {
+		return
+	}
+	c.reload(k, ttl, func() (string, error) {
+		return c.refreshLoader(k)
+	})
+}
+
+// GetAndRefresh returns the value stored under k like GetStale and, if it
+// has expired or is absent, reloads it in the background with loader,
+// storing the result with the given expiry. It never waits for loader. The
+// reload shares k's in-flight slot with GetOrLoad, so at most one runs per
+// key, and a loader error leaves the cache as it is.
+func (c *Cache) GetAndRefresh(k string, expiry time.Duration, loader func() (string, error)) (value string, stale bool, ok bool) {
+	value, stale, ok = c.GetStale(k)
+	if !ok || stale {
+		c.reload(k, expiry, loader)
+	}
+	return value, stale, ok
+}
+
+// reload runs loader in the background and stores its result under k,
+// unless a load of k is already in flight. The reload takes k's in-flight
+// slot, so it never runs alongside another reload or a GetOrLoad of the
+// same key. A loader that panics is treated like one that returned an error:
+// the cache is left as it was.
+func (c *Cache) reload(k string, expiry time.Duration, loader func() (string, error)) {
+	c.loadMu.Lock()
+	if _, ok := c.loads[k]; ok {
+		c.loadMu.Unlock()
+		return
+	}
+	cl := &call{done: make(chan struct{})}
+	c.loads[k] = cl
+	c.loadMu.Unlock()
+
+	go func() {
+		defer c.finishLoad(k, cl)
+		// Nothing would recover a panic in this goroutine, so it fails the
+		// reload like a loader error instead of the process.
+		defer func() {
+			if recover() != nil {
+				cl.val, cl.err = "", keyError("load", k, ErrLoaderPanicked)
+			}
+		}()
+		cl.val, cl.err = loader()
+		if cl.err == nil {
+			c.Set(k, cl.val, expiry)
+		}
+	}()
+}
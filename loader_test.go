@@ -0,0 +1,298 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	c := NewCache(time.Minute)
+	calls := 0
+	loader := func() (string, error) {
+		calls++
+		return "loaded", nil
+	}
+
+	for i := 0; i < 3; i++ {
+		v, err := c.GetOrLoad("a", time.Hour, loader)
+		if err != nil || v != "loaded" {
+			t.Fatalf("GetOrLoad = %q, %v; want \"loaded\", nil", v, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times, want 1", calls)
+	}
+}
+
+func TestGetOrLoadWarmKey(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "cached", time.Hour)
+
+	v, err := c.GetOrLoad("a", time.Hour, func() (string, error) {
+		t.Fatal("loader called for a warm key")
+		return "", nil
+	})
+	if err != nil || v != "cached" {
+		t.Fatalf("GetOrLoad = %q, %v; want \"cached\", nil", v, err)
+	}
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	c := NewCache(time.Minute)
+	errLoad := errors.New("backend down")
+
+	if _, err := c.GetOrLoad("a", time.Hour, func() (string, error) { return "", errLoad }); err != errLoad {
+		t.Fatalf("GetOrLoad err = %v, want %v", err, errLoad)
+	}
+	if c.Has("a") {
+		t.Fatal("GetOrLoad stored a value after a loader error")
+	}
+}
+
+func TestGetOrLoadErrorCaching(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithErrorCaching(time.Second))
+	errLoad := errors.New("backend down")
+	calls := 0
+	loader := func() (string, error) {
+		if calls++; calls == 1 {
+			return "", errLoad
+		}
+		return "loaded", nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetOrLoad("a", time.Hour, loader); err != errLoad {
+			t.Fatalf("GetOrLoad err = %v, want %v", err, errLoad)
+		}
+		clock.Advance(400 * time.Millisecond)
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times within the error-cache window, want 1", calls)
+	}
+	if v, err := c.GetOrLoad("b", time.Hour, loader); err != nil || v != "loaded" {
+		t.Fatalf("GetOrLoad(b) = %q, %v; the cached error leaked to another key", v, err)
+	}
+
+	clock.Advance(400 * time.Millisecond)
+	if v, err := c.GetOrLoad("a", time.Hour, loader); err != nil || v != "loaded" || calls != 3 {
+		t.Fatalf("GetOrLoad after the window = %q, %v with %d calls; want \"loaded\", nil with 3", v, err, calls)
+	}
+}
+
+func TestGetOrLoadDoesNotHoldLock(t *testing.T) {
+	c := NewCache(time.Minute)
+	v, err := c.GetOrLoad("a", time.Hour, func() (string, error) {
+		// Writing from the loader would deadlock if the lock were held.
+		c.Set("other", "x", time.Hour)
+		return "1", nil
+	})
+	if err != nil || v != "1" {
+		t.Fatalf("GetOrLoad = %q, %v; want \"1\", nil", v, err)
+	}
+}
+
+func TestGetOrLoadSingleFlight(t *testing.T) {
+	c := NewCache(time.Minute)
+	var calls int32
+	release := make(chan struct{})
+	loader := func() (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "loaded", nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad("a", time.Hour, loader)
+			if err == nil && v != "loaded" {
+				err = fmt.Errorf("GetOrLoad = %q, want \"loaded\"", v)
+			}
+			errs <- err
+		}()
+	}
+
+	// Give the callers time to pile up on the in-flight load.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("loader called %d times, want 1", n)
+	}
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	c := NewCache(time.Minute)
+	started, release := make(chan struct{}), make(chan struct{})
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		c.GetOrLoad("a", time.Hour, func() (string, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	waiter := make(chan error)
+	go func() {
+		_, err := c.GetOrLoad("a", time.Hour, func() (string, error) { return "unused", nil })
+		waiter <- err
+	}()
+	time.Sleep(20 * time.Millisecond) // let the waiter join the load
+	close(release)
+
+	if p := <-panicked; p != "boom" {
+		t.Fatalf("recovered %v, want the loader's panic", p)
+	}
+	select {
+	case err := <-waiter:
+		if !errors.Is(err, ErrLoaderPanicked) {
+			t.Fatalf("waiter err = %v, want ErrLoaderPanicked", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a waiter on a panicked load never returned")
+	}
+	if v, err := c.GetOrLoad("a", time.Hour, func() (string, error) { return "loaded", nil }); err != nil || v != "loaded" {
+		t.Fatalf("GetOrLoad after a panic = %q, %v; want \"loaded\", nil", v, err)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	clock := newFakeClock()
+	var calls int32
+	release := make(chan struct{})
+	c := New(WithClock(clock.Now), WithRefreshAhead(2*time.Second, func(k string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "new", nil
+	}))
+	c.Set("a", "old", 10*time.Second)
+
+	if v, _ := c.Get("a"); v != "old" {
+		t.Fatalf("Get(a) = %q, want \"old\"", v)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("loader called %d times outside the window, want 0", n)
+	}
+
+	clock.Advance(9 * time.Second)
+	for i := 0; i < 5; i++ {
+		if v, _ := c.Get("a"); v != "old" {
+			t.Fatalf("Get(a) during refresh = %q, want \"old\"", v)
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, _ := c.Get("a"); v == "new" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refresh did not update the value")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("loader called %d times, want 1", n)
+	}
+	if ttl, _ := c.TTL("a"); ttl != 10*time.Second {
+		t.Fatalf("TTL(a) after refresh = %v, want 10s", ttl)
+	}
+}
+
+func TestGetAndRefresh(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	c.Set("a", "old", time.Second)
+	var calls int32
+	release := make(chan struct{})
+	loader := func() (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "new", nil
+	}
+
+	if v, stale, ok := c.GetAndRefresh("a", time.Hour, loader); v != "old" || stale || !ok {
+		t.Fatalf("GetAndRefresh on a live item = %q, %v, %v; want \"old\", false, true", v, stale, ok)
+	}
+
+	clock.Advance(2 * time.Second)
+	// The loader blocks until release is closed, so these calls return
+	// without waiting for it.
+	for i := 0; i < 3; i++ {
+		if v, stale, ok := c.GetAndRefresh("a", time.Hour, loader); v != "old" || !stale || !ok {
+			t.Fatalf("GetAndRefresh on an expired item = %q, %v, %v; want \"old\", true, true", v, stale, ok)
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, ok := c.Get("a"); ok && v == "new" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the background reload did not store the new value")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("loader called %d times, want 1", n)
+	}
+	if ttl, _ := c.TTL("a"); ttl != time.Hour {
+		t.Fatalf("TTL(a) after the reload = %v, want 1h", ttl)
+	}
+}
+
+func TestReloadPanic(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	c.Set("a", "old", time.Second)
+	clock.Advance(2 * time.Second)
+
+	done := make(chan struct{})
+	c.GetAndRefresh("a", time.Hour, func() (string, error) {
+		defer close(done)
+		panic("boom")
+	})
+	<-done
+
+	// The in-flight slot is freed once the panic is recovered.
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.loadMu.Lock()
+		_, loading := c.loads["a"]
+		c.loadMu.Unlock()
+		if !loading {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("a panicking reload kept the in-flight slot")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if v, stale, ok := c.GetStale("a"); v != "old" || !stale || !ok {
+		t.Fatalf("GetStale = %q, %v, %v after a panicking reload; want \"old\", true, true", v, stale, ok)
+	}
+	if v, err := c.GetOrLoad("a", time.Hour, func() (string, error) { return "new", nil }); err != nil || v != "new" {
+		t.Fatalf("GetOrLoad after a panicking reload = %q, %v; want \"new\", nil", v, err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// logInterval is the least time between two entries for events that can
+// happen on every write.
+const logInterval = time.Second
+
+// WithLogger makes the cache report notable events to log, with the event
+// name and fields describing it:
+//
+//	"sweep"              after every janitor sweep: "expired" and "idle"
+//	                     items removed, "items" left, sweep "duration"
+//	"evict"              items evicted to make room: "count" since the
+//	                     last entry
+//	"read_only_rejected" writes rejected in read-only mode: "count" since
+//	                     the last entry
+//	"load_failed"        the WithPersistencePath file could not be read:
+//	                     its "path" and the "error"
+//	"autosave_failed"    a WithAutosave save failed: its "path" and the
+//	                     "error"
+//	"save_failed"        a WithWriteThrough save failed: the "key" and
+//	                     the "error"
+//
+// "evict" and "read_only_rejected" are logged at most once per second, with
+// the counts added up in between. log is never called with the lock held.
+// By default nothing is logged.
+func WithLogger(log func(event string, fields map[string]any)) Option {
+	return func(c *Cache) {
+		c.logger = log
+	}
+}
+
+// rateLog coalesces a frequent event into at most one entry per logInterval.
+type rateLog struct {
+	last    int64 // UnixNano of the last entry
+	pending int64 // occurrences since the last entry
+}
+
+// add records n occurrences at now and, if an entry is due, returns the
+// number of occurrences it should report.
+func (r *rateLog) add(now time.Time, n int64) (int64, bool) {
+	atomic.AddInt64(&r.pending, n)
+	last := atomic.LoadInt64(&r.last)
+	if now.UnixNano()-last < int64(logInterval) ||
+		!atomic.CompareAndSwapInt64(&r.last, last, now.UnixNano()) {
+		return 0, false
+	}
+	return atomic.SwapInt64(&r.pending, 0), true
+}
+
+// logRated records n occurrences of event and logs them if an entry is due.
+func (c *Cache) logRated(r *rateLog, event string, n int64) {
+	if count, ok := r.add(c.clock(), n); ok {
+		c.logger(event, map[string]any{"count": count})
+	}
+}
+
+// sweep is the janitor's periodic cleanup: it passes expired items to the
+// WithBeforeReap hook, removes expired and idle items, compacts the items
+// map and logs a summary.
+func (c *Cache) sweep() {
+	start := c.clock()
+	now := start.UnixNano()
+	if c.beforeReap != nil {
+		c.announceReap(now)
+	}
+	c.mu.Lock()
+	expired, idle := c.deleteExpiredLocked(now)
+	items := len(c.items)
+	c.unlock()
+	c.removeLoadErrors(now)
+	c.Compact()
+
+	if c.logger != nil {
+		c.logger("sweep", map[string]any{
+			"expired":  expired,
+			"idle":     idle,
+			"items":    items,
+			"duration": c.clock().Sub(start),
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type logEntry struct {
+	event  string
+	fields map[string]any
+}
+
+// logRecorder collects the entries passed to a WithLogger function.
+type logRecorder struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (r *logRecorder) log(event string, fields map[string]any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, logEntry{event, fields})
+}
+
+func (r *logRecorder) take() []logEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.entries
+	r.entries = nil
+	return entries
+}
+
+func TestLoggerSweep(t *testing.T) {
+	clock := newFakeClock()
+	var rec logRecorder
+	c := New(WithClock(clock.Now), WithLogger(rec.log), WithIdleTimeout(time.Hour))
+	c.Set("expired1", "1", time.Second)
+	c.Set("expired2", "2", time.Second)
+	c.Set("idle", "3", NoExpiration)
+	clock.Advance(2 * time.Second)
+	c.Set("live", "4", time.Hour)
+	clock.Advance(time.Hour - time.Second)
+
+	c.sweep()
+	entries := rec.take()
+	if len(entries) != 1 || entries[0].event != "sweep" {
+		t.Fatalf("entries = %v, want one sweep", entries)
+	}
+	f := entries[0].fields
+	if f["expired"] != 2 || f["idle"] != 1 || f["items"] != 1 {
+		t.Fatalf("sweep fields = %v, want 2 expired, 1 idle, 1 item", f)
+	}
+}
+
+func TestLoggerRateLimits(t *testing.T) {
+	clock := newFakeClock()
+	var rec logRecorder
+	c := New(WithClock(clock.Now), WithLogger(rec.log), WithMaxItems(1))
+
+	for i := 0; i < 5; i++ {
+		c.Set(string(rune('a'+i)), "v", 0)
+	}
+	// The first eviction is logged, the other three wait for the interval.
+	clock.Advance(logInterval)
+	c.Set("z", "v", 0)
+
+	c.SaveAndExit("")
+	c.Set("a", "v", 0)
+	c.Delete("a")
+
+	var counts []any
+	for _, e := range rec.take() {
+		counts = append(counts, e.event, e.fields["count"])
+	}
+	want := []any{"evict", int64(1), "evict", int64(4), "read_only_rejected", int64(1)}
+	if len(counts) != len(want) {
+		t.Fatalf("logged %v, want %v", counts, want)
+	}
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Fatalf("logged %v, want %v", counts, want)
+		}
+	}
+}
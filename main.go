@@ -3,9 +3,15 @@ package main
 import (
 	"bytes"
 	"compress/gzip"
+	"container/list"
+	"crypto/cipher"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,144 +21,1571 @@ import (
 // test
 // review line by line in future
 
+const (
+	// NoExpiration may be passed as an expiry to store an item that never
+	// expires. Any negative expiry has the same effect.
+	NoExpiration time.Duration = -1
+	// DefaultExpiration may be passed as an expiry to use the cache's
+	// default expiry.
+	DefaultExpiration time.Duration = 0
+)
+
 type item struct {
-	val    []byte
-	expiry int64
+	// Updated atomically by readers; kept first for 64-bit alignment on
+	// 32-bit platforms.
+	hits       uint64
+	lastAccess int64 // UnixNano
+
+	key        string
+	val        []byte
+	compressed bool
+	expiry     int64         // UnixNano; 0 means the item never expires
+	ttl        time.Duration // lifetime expiry was computed from
+	createdAt  int64         // UnixNano
+	tags       []string      // see SetWithTags
+	cost       int64         // see SetWithCost
+	version    uint64        // see SetVersioned
+
+	prev, next *item // position in Cache.order
+	hot        bool  // in the hot segment under EvictSegmentedLRU
+	heapIndex  int   // position in Cache.expiries, or -1
+
+	quota     *prefixQuota  // nil unless the key has a WithPrefixQuota quota
+	quotaElem *list.Element // position in quota.items
+}
+
+func (it *item) expired(now int64) bool {
+	return it.expiry > 0 && now > it.expiry
 }
 
 type Cache struct {
-	mu            *sync.RWMutex
-	items         map[string]*item
-	defaultExpiry time.Duration
-	readOnly      int32
+	// Accessed atomically; kept first for 64-bit alignment on 32-bit
+	// platforms.
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	size        int64 // number of items in the map
+	evictLog    rateLog
+	readOnlyLog rateLog
+
+	mu               *sync.RWMutex
+	items            map[string]*item
+	peakItems        int     // largest len(items) since the map was built
+	compactThreshold float64 // see WithCompactThreshold
+	order            itemList
+	hotTail          *item   // last item of the hot segment, see WithSegmentedLRU
+	hotItems         int     // items in the hot segment
+	hotFraction      float64 // share of the capacity the hot segment may use
+	expiries         expiryHeap
+	defaultExpiry    time.Duration
+	janitorInterval  time.Duration // 0 means twice the default expiry
+	maxItems         int           // 0 means unbounded
+	evictBatch       int           // most items one eviction removes; 0 means all
+	maxBytes         int64         // 0 means unbounded
+	bytes            int64         // total size of stored values
+	maxCost          int64         // 0 means unbounded
+	cost             int64         // total cost of stored items
+	version          uint64        // last version given to an item
+	maxValueSize     int           // 0 means unbounded
+	minTTL           time.Duration // shortest item lifetime; 0 means none
+	writeLimit       *rateLimiter  // nil means unlimited
+	policy           EvictionPolicy
+	admission        AdmissionPolicy
+	sketch           *countMinSketch // nil unless admission is TinyLFU
+	hot              *hotKeys        // nil unless WithHotKeys is set
+	quotas           []*prefixQuota
+	bloom            atomic.Value // *bloomFilter, read without the lock
+	sliding          bool
+	jitter           float64       // see WithExpiryJitter
+	rng              *rand.Rand    // used under the write lock
+	idleTimeout      time.Duration // 0 disables idle eviction
+	compressMin      int           // 0 disables compression
+	aead             cipher.AEAD   // nil disables encryption
+	aeadErr          error         // why WithEncryption could not set aead
+	clock            func() time.Time
+	onEvicted        func(key, value string, reason EvictionReason)
+	evicted          []evictedItem // removed while the write lock is held
+	capacityEvicted  int64         // evictions to log once the lock is released
+	logger           func(event string, fields map[string]any)
+	expirations      chan string
+	blockExpirations bool
+	readOnly         int32
+	janitorPaused    int32
+	stop             chan struct{}
+	stopOnce         sync.Once
+
+	tags       map[string]map[string]struct{} // keys by tag
+	tombstones map[string]int64               // expiry of cached misses; 0 means never
+
+	store         Store
+	saves         []pendingSave // queued for the store while the write lock is held
+	loadMu        sync.Mutex
+	loads         map[string]*call   // in-flight loads by key
+	loadErrs      map[string]loadErr // cached loader errors by key
+	errorTTL      time.Duration
+	refreshWindow time.Duration
+	refreshLoader func(key string) (string, error)
+	persistPath   string
+
+	watchMu     sync.RWMutex
+	watchers    map[string][]chan Event
+	watching    int32 // number of watch channels
+	watchBuffer int
+	watchQueue  []watchEvent // queued while the write lock is held
+
+	queueMax int // see WithReadOnlyQueue
+	queueMu  sync.Mutex
+	queued   []queuedWrite
+
+	autosavePath     string
+	autosaveInterval time.Duration
+	beforeReap       func(key, value string)
+	reapSignal       chan struct{} // wakes the reaper; nil reaps inline
+	reapPending      int32         // 1 once the reaper has been woken
+}
+
+func NewCache(ed time.Duration, opts ...Option) *Cache {
+	return newCache(append([]Option{WithDefaultExpiry(ed)}, opts...))
+}
+
+func NewCacheWithJanitor(ed time.Duration, maxItems int, opts ...Option) *Cache {
+	// maxItems goes last so it wins over a WithMaxItems in opts, and before
+	// newCache sizes the admission sketch and loads a persistence file.
+	all := make([]Option, 0, len(opts)+2)
+	all = append(all, WithDefaultExpiry(ed))
+	all = append(all, opts...)
+	all = append(all, WithMaxItems(maxItems))
+	c := newCache(all)
+
+	go c.janitor()
+
+	return c
 }
 
-func NewCache(ed time.Duration) *Cache {
-	return &Cache{
-		mu:            &sync.RWMutex{},
-		items:         make(map[string]*item),
-		defaultExpiry: ed,
+// NewCacheFromMap creates a cache holding a copy of m, every item expiring
+// after expiry, which also becomes the cache's default expiry.
+func NewCacheFromMap(m map[string]string, expiry time.Duration, opts ...Option) *Cache {
+	c := NewCache(expiry, opts...)
+
+	c.mu.Lock()
+	defer c.unlock()
+	for k, v := range m {
+		val, compressed, err := c.encode(v)
+		if err != nil {
+			continue
+		}
+		c.setLocked(k, val, compressed, expiry)
 	}
+	return c
 }
 
-func NewCacheWithJanitor(ed time.Duration, maxItems int) *Cache {
+func newCache(opts []Option) *Cache {
 	c := &Cache{
-		mu:            &sync.RWMutex{},
-		items:         make(map[string]*item),
-		defaultExpiry: ed,
+		mu:               &sync.RWMutex{},
+		items:            make(map[string]*item),
+		stop:             make(chan struct{}),
+		loads:            make(map[string]*call),
+		clock:            time.Now,
+		compactThreshold: defaultCompactThreshold,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.admission == TinyLFU {
+		c.sketch = newCountMinSketch(c.maxItems)
+	}
+	c.restore()
+	if c.autosaveInterval > 0 {
+		go c.autosave()
+	}
+	if c.reapSignal != nil {
+		go c.reaper()
+	}
+	return c
+}
 
-	go c.janitor(maxItems)
+// Set stores v under k. It is TrySet without the error; a write the cache
+// rejects is dropped, except that an expiry below the WithMinTTL minimum is
+// raised to it.
+func (c *Cache) Set(k, v string, expiry time.Duration) {
+	if c.tooShort(expiry) {
+		expiry = c.minTTL
+	}
+	c.TrySet(k, v, expiry)
+}
 
-	return c
+// TrySet stores v under k, reporting why the write was rejected if it was:
+// ErrReadOnly in read-only mode (ErrQueueFull with WithReadOnlyQueue),
+// ErrTTLTooShort for an expiry below the WithMinTTL minimum, ErrRateLimited
+// over the write rate limit, or ErrCapacity if v on its own does not fit in
+// the byte limit.
+func (c *Cache) TrySet(k, v string, expiry time.Duration) error {
+	return c.trySet(k, v, expiry, c.lockWrite)
 }
 
-func (c *Cache) Set(k, v string, maxItems int, expiry time.Duration) {
-	if atomic.LoadInt32(&c.readOnly) == 1 {
-		return
+// trySet is TrySet taking the write lock with lock, so that SetContext can
+// share the write path.
+func (c *Cache) trySet(k, v string, expiry time.Duration, lock func() error) error {
+	if c.tooShort(expiry) {
+		return keyError("set", k, ErrTTLTooShort)
+	}
+	if c.rateLimited() {
+		return keyError("set", k, ErrRateLimited)
+	}
+	if queued, err := c.queueWrite(k, v, expiry); queued {
+		if err != nil {
+			return keyError("set", k, err)
+		}
+		return nil
+	}
+	if err := c.set(k, v, expiry, lock); err != nil {
+		return keyError("set", k, err)
+	}
+	if c.store != nil {
+		if err := c.store.Save(k, v, c.lifetime(expiry)); err != nil {
+			return keyError("save", k, err)
+		}
 	}
+	return nil
+}
 
+// lockWrite takes the write lock. It is the lock function for writes that
+// cannot be canceled.
+func (c *Cache) lockWrite() error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	return nil
+}
 
-	// Check if the number of items in the cache exceeds the maximum limit.
-	if len(c.items) >= maxItems {
-		c.cleanup()
+// set stores v under k, taking the write lock with lock, without writing it
+// through to the store.
+func (c *Cache) set(k, v string, expiry time.Duration, lock func() error) error {
+	if c.isReadOnly() {
+		return ErrReadOnly
 	}
 
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	_, err := gz.Write([]byte(v))
+	val, compressed, err := c.encode(v)
 	if err != nil {
+		return err
+	}
+	return c.setEncoded(k, val, compressed, expiry, lock)
+}
+
+// setEncoded stores an already encoded value, taking the write lock with
+// lock.
+func (c *Cache) setEncoded(k string, val []byte, compressed bool, expiry time.Duration, lock func() error) error {
+	if c.maxBytes > 0 && int64(len(val)) > c.maxBytes {
+		return ErrCapacity
+	}
+
+	if err := lock(); err != nil {
+		return err
+	}
+	defer c.unlock()
+	if !c.setLocked(k, val, compressed, expiry) {
+		return ErrCapacity
+	}
+	return nil
+}
+
+// SetMulti stores all entries with the same expiry under a single lock.
+func (c *Cache) SetMulti(entries map[string]string, expiry time.Duration) {
+	if c.isReadOnly() || c.rateLimited() {
+		return
+	}
+
+	type encoded struct {
+		val        []byte
+		compressed bool
+	}
+	enc := make(map[string]encoded, len(entries))
+	for k, v := range entries {
+		val, compressed, err := c.encode(v)
+		if err != nil {
+			continue
+		}
+		enc[k] = encoded{val, compressed}
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+
+	// Make room for the whole batch at once rather than item by item.
+	if c.maxItems > 0 {
+		added := 0
+		for k := range enc {
+			if _, ok := c.items[k]; !ok {
+				added++
+			}
+		}
+		if len(c.items)+added > c.maxItems {
+			c.evictLocked(c.maxItems-added, c.cleanupLocked())
+		}
+	}
+
+	for k, e := range enc {
+		if c.setLocked(k, e.val, e.compressed, expiry) {
+			c.saveLocked(k, entries[k])
+		}
+	}
+}
+
+// SetDefault stores v under k using the cache's default expiry.
+func (c *Cache) SetDefault(k, v string) {
+	if c.isReadOnly() || c.rateLimited() {
 		return
 	}
-	err = gz.Close()
+
+	val, compressed, err := c.encode(v)
 	if err != nil {
 		return
 	}
 
-	c.items[k] = &item{
-		val:    buf.Bytes(),
-		expiry: time.Now().Add(expiry).UnixNano(),
+	c.mu.Lock()
+	defer c.unlock()
+	if c.setLocked(k, val, compressed, DefaultExpiration) {
+		c.saveLocked(k, v)
+	}
+}
+
+// Add stores v under k only if k does not hold a live item. An expired item
+// is treated as absent and overwritten.
+func (c *Cache) Add(k, v string, expiry time.Duration) bool {
+	if c.isReadOnly() || c.rateLimited() {
+		return false
+	}
+
+	val, compressed, err := c.encode(v)
+	if err != nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	if old, ok := c.items[k]; ok && !old.expired(c.clock().UnixNano()) {
+		return false
+	}
+
+	if !c.setLocked(k, val, compressed, expiry) {
+		return false
 	}
+	c.saveLocked(k, v)
+	return true
+}
+
+// Replace stores v under k only if k already holds a live item.
+func (c *Cache) Replace(k, v string, expiry time.Duration) bool {
+	return c.TryReplace(k, v, expiry) == nil
 }
 
+// TryReplace is like Replace but reports why nothing was stored: ErrReadOnly,
+// ErrRateLimited, ErrNotFound or ErrExpired.
+func (c *Cache) TryReplace(k, v string, expiry time.Duration) error {
+	if c.isReadOnly() {
+		return keyError("replace", k, ErrReadOnly)
+	}
+	if c.rateLimited() {
+		return keyError("replace", k, ErrRateLimited)
+	}
+
+	val, compressed, err := c.encode(v)
+	if err != nil {
+		return keyError("replace", k, err)
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	if _, err := c.liveLocked(k); err != nil {
+		return keyError("replace", k, err)
+	}
+
+	if c.setLocked(k, val, compressed, expiry) {
+		c.saveLocked(k, v)
+	}
+	return nil
+}
+
+// GetSet stores v under k and returns the live value it replaced, if any.
+func (c *Cache) GetSet(k, v string, expiry time.Duration) (string, bool) {
+	if c.isReadOnly() || c.rateLimited() {
+		return "", false
+	}
+
+	val, compressed, err := c.encode(v)
+	if err != nil {
+		return "", false
+	}
+
+	c.mu.Lock()
+	old, ok := c.items[k]
+	var oldVal []byte
+	var oldCompressed bool
+	if ok && !old.expired(c.clock().UnixNano()) {
+		oldVal, oldCompressed = old.val, old.compressed
+	}
+	if c.setLocked(k, val, compressed, expiry) {
+		c.saveLocked(k, v)
+	}
+	c.unlock()
+
+	if oldVal == nil {
+		return "", false
+	}
+	prev, err := c.decode(oldVal, oldCompressed)
+	if err != nil {
+		return "", false
+	}
+	return prev, true
+}
+
+// SetReturning stores v under k and reports the live value it overwrote.
+// It is the same operation as GetSet, named for update flows.
+func (c *Cache) SetReturning(k, v string, expiry time.Duration) (prev string, replaced bool) {
+	return c.GetSet(k, v, expiry)
+}
+
+// CompareAndSwap stores new under k only if k holds a live item whose value
+// is old. It reports whether the swap happened.
+func (c *Cache) CompareAndSwap(k, old, new string, expiry time.Duration) bool {
+	if c.isReadOnly() || c.rateLimited() {
+		return false
+	}
+
+	val, compressed, err := c.encode(new)
+	if err != nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	if !c.holdsLocked(k, old) {
+		return false
+	}
+
+	if c.setLocked(k, val, compressed, expiry) {
+		c.saveLocked(k, new)
+	}
+	return true
+}
+
+// CompareAndDelete removes k only if it holds a live item whose value is
+// old. It reports whether the item was removed.
+func (c *Cache) CompareAndDelete(k, old string) bool {
+	if c.isReadOnly() {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	if !c.holdsLocked(k, old) {
+		return false
+	}
+
+	c.removeLocked(k, ReasonDeleted)
+	return true
+}
+
+// SetIfGreater stores v under k only if it is greater than the integer k
+// holds, or if k holds no live item. It returns the integer stored under k
+// afterwards and whether v was stored. It returns 0, false without storing v
+// in read-only mode, over the write rate limit or if k holds a value that is
+// not an integer.
+func (c *Cache) SetIfGreater(k string, v int64, expiry time.Duration) (int64, bool) {
+	if c.isReadOnly() || c.rateLimited() {
+		return 0, false
+	}
+
+	val, compressed, err := c.encode(strconv.FormatInt(v, 10))
+	if err != nil {
+		return 0, false
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	if it, err := c.liveLocked(k); err == nil {
+		s, err := c.decode(it.val, it.compressed)
+		if err != nil {
+			return 0, false
+		}
+		cur, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		if v <= cur {
+			return cur, false
+		}
+	}
+
+	if !c.setLocked(k, val, compressed, expiry) {
+		return 0, false
+	}
+	c.saveLocked(k, strconv.FormatInt(v, 10))
+	return v, true
+}
+
+// liveLocked returns the live item stored under k, or ErrNotFound or
+// ErrExpired. The caller must hold the lock.
+func (c *Cache) liveLocked(k string) (*item, error) {
+	it, ok := c.items[k]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if it.expired(c.clock().UnixNano()) {
+		return nil, ErrExpired
+	}
+	return it, nil
+}
+
+// holdsLocked reports whether k holds a live item whose value is v. The
+// caller must hold the write lock.
+func (c *Cache) holdsLocked(k, v string) bool {
+	it, ok := c.items[k]
+	if !ok || it.expired(c.clock().UnixNano()) {
+		return false
+	}
+	cur, err := c.decode(it.val, it.compressed)
+	return err == nil && cur == v
+}
+
+// Increment adds n to the integer stored under k and returns the result.
+// The item keeps its current expiry.
+func (c *Cache) Increment(k string, n int64) (int64, error) {
+	if c.isReadOnly() {
+		return 0, keyError("increment", k, ErrReadOnly)
+	}
+	if c.rateLimited() {
+		return 0, keyError("increment", k, ErrRateLimited)
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	v, err := c.liveLocked(k)
+	if err != nil {
+		return 0, keyError("increment", k, err)
+	}
+
+	s, err := c.decode(v.val, v.compressed)
+	if err != nil {
+		return 0, keyError("increment", k, err)
+	}
+	cur, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, keyError("increment", k, ErrNotInteger)
+	}
+	if (n > 0 && cur > math.MaxInt64-n) || (n < 0 && cur < math.MinInt64-n) {
+		return 0, keyError("increment", k, ErrOverflow)
+	}
+
+	s = strconv.FormatInt(cur+n, 10)
+	val, compressed, err := c.encode(s)
+	if err != nil {
+		return 0, keyError("increment", k, err)
+	}
+	c.setValueLocked(v, val, compressed)
+	c.saveLocked(k, s)
+	return cur + n, nil
+}
+
+// Decrement subtracts n from the integer stored under k and returns the
+// result.
+func (c *Cache) Decrement(k string, n int64) (int64, error) {
+	if n == math.MinInt64 {
+		return 0, keyError("decrement", k, ErrOverflow)
+	}
+	return c.Increment(k, -n)
+}
+
+// IncrementFloat adds delta to the number stored under k and returns the
+// result, stored in the shortest form that parses back to the same float64.
+// err wraps ErrNotFloat if the value or delta is not a finite number, except
+// that an infinite delta or result wraps ErrOverflow. The item keeps its
+// current expiry.
+func (c *Cache) IncrementFloat(k string, delta float64) (float64, error) {
+	if math.IsNaN(delta) {
+		return 0, keyError("increment", k, ErrNotFloat)
+	}
+	if math.IsInf(delta, 0) {
+		return 0, keyError("increment", k, ErrOverflow)
+	}
+	if c.isReadOnly() {
+		return 0, keyError("increment", k, ErrReadOnly)
+	}
+	if c.rateLimited() {
+		return 0, keyError("increment", k, ErrRateLimited)
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	v, err := c.liveLocked(k)
+	if err != nil {
+		return 0, keyError("increment", k, err)
+	}
+
+	s, err := c.decode(v.val, v.compressed)
+	if err != nil {
+		return 0, keyError("increment", k, err)
+	}
+	cur, err := strconv.ParseFloat(s, 64)
+	// ParseFloat accepts "NaN" and "Inf", which are not numbers to add to.
+	if err != nil || math.IsNaN(cur) || math.IsInf(cur, 0) {
+		return 0, keyError("increment", k, ErrNotFloat)
+	}
+	f := cur + delta
+	if math.IsInf(f, 0) {
+		return 0, keyError("increment", k, ErrOverflow)
+	}
+
+	s = strconv.FormatFloat(f, 'g', -1, 64)
+	val, compressed, err := c.encode(s)
+	if err != nil {
+		return 0, keyError("increment", k, err)
+	}
+	c.setValueLocked(v, val, compressed)
+	c.saveLocked(k, s)
+	return f, nil
+}
+
+// Append adds suffix to the end of the live value stored under k, or stores
+// suffix if there is none, and returns the length of the result in bytes.
+// The item's lifetime is reset to expiry.
+func (c *Cache) Append(k, suffix string, expiry time.Duration) (int, error) {
+	if c.isReadOnly() {
+		return 0, keyError("append", k, ErrReadOnly)
+	}
+	if c.rateLimited() {
+		return 0, keyError("append", k, ErrRateLimited)
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	var cur string
+	if it, err := c.liveLocked(k); err == nil {
+		if cur, err = c.decode(it.val, it.compressed); err != nil {
+			return 0, keyError("append", k, err)
+		}
+	}
+
+	v := cur + suffix
+	val, compressed, err := c.encode(v)
+	if err != nil {
+		return 0, keyError("append", k, err)
+	}
+	if !c.setLocked(k, val, compressed, expiry) {
+		return 0, keyError("append", k, ErrCapacity)
+	}
+	c.saveLocked(k, v)
+	return len(v), nil
+}
+
+// GetInt returns the live value stored under k parsed as a base 10 integer.
+// ok is false if k holds no live item; err wraps ErrNotInteger if the value
+// is not an integer.
+func (c *Cache) GetInt(k string) (n int64, ok bool, err error) {
+	v, ok := c.Get(k)
+	if !ok {
+		return 0, false, nil
+	}
+	if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+		return 0, true, keyError("get", k, ErrNotInteger)
+	}
+	return n, true, nil
+}
+
+// GetFloat is like GetInt for floating-point numbers; err wraps ErrNotFloat
+// if the value is not a number.
+func (c *Cache) GetFloat(k string) (f float64, ok bool, err error) {
+	v, ok := c.Get(k)
+	if !ok {
+		return 0, false, nil
+	}
+	if f, err = strconv.ParseFloat(v, 64); err != nil {
+		return 0, true, keyError("get", k, ErrNotFloat)
+	}
+	return f, true, nil
+}
+
+// GetOrDelete is kept for backward compatibility; Get now removes expired
+// items itself.
 func (c *Cache) GetOrDelete(k string) (string, bool) {
+	return c.Get(k)
+}
+
+// Get returns the value stored under k. An expired item is removed from the
+// cache and reported as missing.
+func (c *Cache) Get(k string) (string, bool) {
+	if c.bloomMiss(k) {
+		return "", false
+	}
+	v, _, err := c.get(k, c.lockForRead())
+	return v, err == nil
+}
+
+// Lookup is like Get but tells a missing key (ErrNotFound) from an expired
+// one (ErrExpired).
+func (c *Cache) Lookup(k string) (string, error) {
+	if c.bloomMiss(k) {
+		return "", keyError("lookup", k, ErrNotFound)
+	}
+	v, _, err := c.get(k, c.lockForRead())
+	if err != nil {
+		return "", keyError("lookup", k, err)
+	}
+	return v, nil
+}
+
+// GetWithExpiry is like Get but also returns when the item expires. The
+// expiry is the zero time for items that never expire.
+func (c *Cache) GetWithExpiry(k string) (string, time.Time, bool) {
+	if c.bloomMiss(k) {
+		return "", time.Time{}, false
+	}
+	v, expiry, err := c.get(k, c.lockForRead())
+	if err != nil || expiry == 0 {
+		return v, time.Time{}, err == nil
+	}
+	return v, time.Unix(0, expiry), true
+}
+
+// get looks up k with the read lock already taken by the caller, releasing
+// it with unlock. It returns the value and its expiry in UnixNano.
+func (c *Cache) get(k string, unlock func()) (string, int64, error) {
+	e, err := c.getEncoded(k, unlock)
+	if err != nil {
+		return "", 0, err
+	}
+	s, err := c.decode(e.val, e.compressed)
+	if err != nil {
+		return "", 0, err
+	}
+	return s, e.expiry, nil
+}
+
+// entry is a copy of the fields of an item taken under the lock.
+type entry struct {
+	val        []byte
+	compressed bool
+	expiry     int64
+	version    uint64
+}
+
+// getEncoded is get returning the value in its stored form.
+func (c *Cache) getEncoded(k string, unlock func()) (entry, error) {
+	if c.sketch != nil {
+		c.sketch.increment(k)
+	}
+	if c.hot != nil {
+		c.hot.record(k)
+	}
+	v, ok := c.items[k]
+	if !ok {
+		unlock()
+		atomic.AddUint64(&c.misses, 1)
+		return entry{}, ErrNotFound
+	}
+	expired := v.expired(c.clock().UnixNano())
+	if !expired {
+		c.touchLocked(v)
+	}
+	e := entry{v.val, v.compressed, v.expiry, v.version}
+	ttl := v.ttl
+	unlock()
+
+	if expired {
+		atomic.AddUint64(&c.misses, 1)
+		if c.reapSignal != nil {
+			c.queueReap()
+		} else {
+			c.deleteIfExpired(k)
+		}
+		return entry{}, ErrExpired
+	}
+	atomic.AddUint64(&c.hits, 1)
+	c.refreshAhead(k, e.expiry, ttl)
+
+	return e, nil
+}
+
+// GetStale returns the value stored under k even if it has expired, with
+// stale reporting whether it has. ok is false only if k is absent. Expired
+// items are still removed by the janitor and by other reads, so a stale value
+// is only available until then. GetStale does not count as a use of the item.
+func (c *Cache) GetStale(k string) (value string, stale bool, ok bool) {
 	c.mu.RLock()
 	v, ok := c.items[k]
 	if !ok {
 		c.mu.RUnlock()
-		return "", false
+		return "", false, false
 	}
-	if time.Now().UnixNano() > v.expiry {
-		c.mu.RUnlock()
-		c.delete(k)
-		return "", false
+	val, compressed := v.val, v.compressed
+	stale = v.expired(c.clock().UnixNano())
+	c.mu.RUnlock()
+
+	s, err := c.decode(val, compressed)
+	if err != nil {
+		return "", false, false
 	}
+	return s, stale, true
+}
 
-	c.mu.RUnlock()
-	return string(v.val), true
+// GetMulti returns the live values stored under keys. Missing and expired
+// keys are left out of the result.
+func (c *Cache) GetMulti(keys []string) map[string]string {
+	type entry struct {
+		key        string
+		val        []byte
+		compressed bool
+	}
+
+	entries := make([]entry, 0, len(keys))
+	unlock := c.lockForRead()
+	now := c.clock().UnixNano()
+	for _, k := range keys {
+		v, ok := c.items[k]
+		if !ok || v.expired(now) {
+			continue
+		}
+		c.touchLocked(v)
+		entries = append(entries, entry{k, v.val, v.compressed})
+	}
+	unlock()
+
+	atomic.AddUint64(&c.hits, uint64(len(entries)))
+	atomic.AddUint64(&c.misses, uint64(len(keys)-len(entries)))
+
+	res := make(map[string]string, len(entries))
+	for _, e := range entries {
+		s, err := c.decode(e.val, e.compressed)
+		if err != nil {
+			continue
+		}
+		res[e.key] = s
+	}
+	return res
 }
 
-func (c *Cache) Get(k string) (string, bool) {
+// lockForRead takes the lock a read needs and returns the matching unlock.
+func (c *Cache) lockForRead() (unlock func()) {
+	if c.readsMutate() {
+		c.mu.Lock()
+		return c.unlock
+	}
+	c.mu.RLock()
+	return c.mu.RUnlock
+}
+
+// Has reports whether k holds an item that has not expired. Unlike Get it
+// never modifies the cache.
+func (c *Cache) Has(k string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.items[k]
+	return ok && !v.expired(c.clock().UnixNano())
+}
+
+// Touch resets the expiry of a live item to expiry from now. It reports
+// false if k is missing or already expired.
+func (c *Cache) Touch(k string, expiry time.Duration) bool {
+	if c.isReadOnly() {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	v, ok := c.items[k]
+	now := c.clock()
+	if !ok || v.expired(now.UnixNano()) {
+		return false
+	}
+
+	c.setExpiry(v, now, expiry)
+	return true
+}
+
+// Metadata returns when the live item stored under k was written and when it
+// was last read. An item that has not been read reports its write time.
+func (c *Cache) Metadata(k string) (created, accessed time.Time, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.items[k]
+	if !ok || v.expired(c.clock().UnixNano()) {
+		return time.Time{}, time.Time{}, false
+	}
+	return time.Unix(0, v.createdAt), time.Unix(0, atomic.LoadInt64(&v.lastAccess)), true
+}
+
+// SetExpiry gives the live item under k a new lifetime of expiry from now,
+// which may be shorter than what it had left. It is equivalent to Touch.
+func (c *Cache) SetExpiry(k string, expiry time.Duration) bool {
+	return c.Touch(k, expiry)
+}
+
+// TTL returns how long the item stored under k has left to live, or
+// NoExpiration if it never expires. It reports false if k is missing or
+// already expired.
+func (c *Cache) TTL(k string) (time.Duration, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	v, ok := c.items[k]
 	if !ok {
-		return "", false
+		return 0, false
+	}
+	if v.expiry == 0 {
+		return NoExpiration, true
 	}
 
-	if time.Now().UnixNano() > v.expiry {
-		return "", false
+	remaining := time.Duration(v.expiry - c.clock().UnixNano())
+	if remaining < 0 {
+		return 0, false
 	}
+	return remaining, true
+}
 
-	buf := bytes.NewBuffer(v.val)
-	gz, err := gzip.NewReader(buf)
-	if err != nil {
-		return "", false
+// Delete removes k from the cache whether or not it has expired.
+// Deleting an absent key is a no-op.
+func (c *Cache) Delete(k string) {
+	if c.isReadOnly() {
+		return
 	}
-	defer gz.Close()
 
-	uncompressed, err := io.ReadAll(gz)
-	if err != nil {
-		return "", false
+	c.delete(k)
+}
+
+// DeletePrefix removes every key that starts with prefix and returns how
+// many were removed.
+func (c *Cache) DeletePrefix(prefix string) int {
+	if c.isReadOnly() {
+		return 0
 	}
 
-	return string(uncompressed), true
+	c.mu.Lock()
+	defer c.unlock()
+	n := 0
+	for k := range c.items {
+		if strings.HasPrefix(k, prefix) {
+			c.removeLocked(k, ReasonDeleted)
+			n++
+		}
+	}
+	return n
 }
 
-func (c *Cache) delete(k string) {
+// DeleteMulti removes all of keys under a single write lock and returns how
+// many of them were present.
+func (c *Cache) DeleteMulti(keys []string) int {
+	if c.isReadOnly() {
+		return 0
+	}
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	delete(c.items, k)
+	defer c.unlock()
+	n := 0
+	for _, k := range keys {
+		if _, ok := c.items[k]; ok {
+			c.removeLocked(k, ReasonDeleted)
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of items that have not expired yet.
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := 0
+	now := c.clock().UnixNano()
+	for _, item := range c.items {
+		if !item.expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// ItemCount returns the number of items in the cache, including expired
+// items that have not been cleaned up yet.
+func (c *Cache) ItemCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.items)
 }
 
-func (c *Cache) SaveAndExit(k string) {
-	atomic.AddInt32(&c.readOnly, 1)
+// Size returns the number of items in the cache, including expired items
+// that have not been cleaned up yet, without taking the lock.
+func (c *Cache) Size() int {
+	return int(atomic.LoadInt64(&c.size))
 }
 
-func (c *Cache) cleanup() {
+// Keys returns the keys of all items that have not expired yet, in no
+// particular order.
+func (c *Cache) Keys() []string {
 	c.mu.RLock()
-	keys := []string{}
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0, len(c.items))
+	now := c.clock().UnixNano()
+	for k, item := range c.items {
+		if !item.expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// MatchKeys returns the keys of all live items matching the shell pattern
+// pattern, with the syntax of filepath.Match; note that * and ? do not match
+// the path separator. A malformed pattern returns filepath.ErrBadPattern.
+func (c *Cache) MatchKeys(pattern string) ([]string, error) {
+	// Match only reports a malformed pattern once it reaches the bad part,
+	// so check the whole pattern up front.
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
+	var keys []string
+	now := c.clock().UnixNano()
 	for k, item := range c.items {
-		if time.Now().UnixNano() > item.expiry {
+		if item.expired(now) {
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, k); ok {
 			keys = append(keys, k)
 		}
 	}
+	return keys, nil
+}
+
+// Range calls f for every live item until f returns false. The read lock is
+// held while f runs, so f must not call methods that modify the cache.
+func (c *Cache) Range(f func(key, value string) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.clock().UnixNano()
+	for k, item := range c.items {
+		if item.expired(now) {
+			continue
+		}
+		v, err := c.decode(item.val, item.compressed)
+		if err != nil {
+			continue
+		}
+		if !f(k, v) {
+			return
+		}
+	}
+}
+
+// Items returns a copy of all live items.
+func (c *Cache) Items() map[string]string {
+	m := make(map[string]string)
+	c.Range(func(key, value string) bool {
+		m[key] = value
+		return true
+	})
+	return m
+}
+
+// Flush removes all items from the cache. The eviction callback is not
+// called for them.
+func (c *Cache) Flush() {
+	if c.isReadOnly() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	c.items = make(map[string]*item)
+	c.peakItems = 0
+	atomic.StoreInt64(&c.size, 0)
+	c.order = itemList{}
+	c.hotTail, c.hotItems = nil, 0
+	c.expiries = nil
+	c.tags = nil
+	c.tombstones = nil
+	c.bytes = 0
+	c.cost = 0
+	for _, q := range c.quotas {
+		q.items.Init()
+	}
+	c.resetBloomLocked()
+}
+
+// ReplaceAll replaces the whole contents of the cache with entries, each
+// expiring after expiry. The new items are built without holding the lock
+// and swapped in at once, so readers see either every old item or every new
+// one, never a mix. Like Flush, it does not fire eviction callbacks for the
+// old items. Capacity limits apply to entries as for Set, and entries that
+// do not fit are reported as evicted.
+func (c *Cache) ReplaceAll(entries map[string]string, expiry time.Duration) {
+	if c.isReadOnly() {
+		return
+	}
+
+	c.mu.RLock()
+	s := c.detached()
+	// Only so that s collects its evictions for c to report; s itself never
+	// calls them.
+	s.onEvicted, s.expirations = c.onEvicted, c.expirations
+	base := c.version
 	c.mu.RUnlock()
 
+	s.version = base
+	s.mu.Lock()
+	for k, v := range entries {
+		val, compressed, err := c.encode(v)
+		if err != nil {
+			continue
+		}
+		s.setLocked(k, val, compressed, expiry)
+	}
+	s.mu.Unlock()
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	for _, k := range keys {
-		delete(c.items, k)
+	defer c.unlock()
+	// Keep versions increasing past those of writes made in the meantime.
+	if d := c.version - base; d > 0 {
+		for _, it := range s.items {
+			it.version += d
+		}
+	}
+	c.version = s.version + c.version - base
+	c.items = s.items
+	c.peakItems = s.peakItems
+	atomic.StoreInt64(&c.size, int64(len(s.items)))
+	c.order = s.order
+	c.hotTail, c.hotItems = s.hotTail, s.hotItems
+	c.expiries = s.expiries
+	c.tags = nil
+	c.tombstones = nil
+	c.bytes = s.bytes
+	c.cost = s.cost
+	c.quotas = s.quotas
+	if b := s.bloomFilter(); b != nil {
+		c.bloom.Store(b)
 	}
+	// Entries that did not fit were evicted from s; report them as c's.
+	c.evicted = append(c.evicted, s.evicted...)
+	c.capacityEvicted += s.capacityEvicted
+	atomic.AddUint64(&c.evictions, atomic.LoadUint64(&s.evictions))
 }
 
-func (c *Cache) janitor(maxItems int) {
+// ExpireAll makes every item expire now without removing it, so reads miss
+// straight away while the items are reaped by the usual expiry path. It is
+// cheaper than Flush for a large cache and fires expiry callbacks as items
+// are reaped.
+func (c *Cache) ExpireAll() {
+	if c.isReadOnly() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	// An item expires once the clock is past its expiry.
+	expiry := c.clock().UnixNano() - 1
+	c.expiries = c.expiries[:0]
+	for _, it := range c.items {
+		it.expiry = expiry
+		it.heapIndex = len(c.expiries)
+		c.expiries = append(c.expiries, it)
+	}
+	// Every item has the same expiry, so any order is a valid heap.
+}
+
+func (c *Cache) delete(k string) {
+	c.mu.Lock()
+	defer c.unlock()
+	c.removeLocked(k, ReasonDeleted)
+}
+
+// setExpiry makes it expire d after now, falling back to the default expiry
+// for DefaultExpiration and applying any expiry jitter. The caller must hold
+// the write lock.
+func (c *Cache) setExpiry(it *item, now time.Time, d time.Duration) {
+	if d = c.lifetime(d); d == 0 {
+		it.ttl, it.expiry = 0, 0
+	} else {
+		it.ttl, it.expiry = d, now.Add(c.jittered(d)).UnixNano()
+	}
+	c.expiries.update(it)
+}
+
+// lifetime resolves a Set expiry to the duration an item lives for, 0
+// meaning forever.
+func (c *Cache) lifetime(d time.Duration) time.Duration {
+	if d == DefaultExpiration {
+		d = c.defaultExpiry
+	}
+	if d < 0 {
+		return 0
+	}
+	if d > 0 && d < c.minTTL {
+		return c.minTTL
+	}
+	return d
+}
+
+// tooShort reports whether an item written with expiry would live for less
+// than the minimum TTL.
+func (c *Cache) tooShort(expiry time.Duration) bool {
+	if expiry == DefaultExpiration {
+		expiry = c.defaultExpiry
+	}
+	return expiry > 0 && expiry < c.minTTL
+}
+
+// setLocked stores an already encoded value, making room for it first if
+// the cache is full. It reports false if the admission policy rejected the
+// value. The caller must hold the write lock.
+func (c *Cache) setLocked(k string, val []byte, compressed bool, expiry time.Duration) bool {
+	_, exists := c.items[k]
+	q := c.quotaFor(k)
+	// Check if the number of items in the cache exceeds the maximum limit.
+	full := !exists && c.maxItems > 0 && len(c.items) >= c.maxItems
+	removed := 0
+	if full || (!exists && q != nil && q.full()) {
+		removed = c.cleanupLocked()
+	}
+	if full {
+		if len(c.items) >= c.maxItems && !c.admitLocked(k) {
+			return false
+		}
+	} else if c.sketch != nil {
+		c.sketch.increment(k)
+	}
+	// Only an admitted key may evict under its quota, and a key evicted
+	// there may already have made room in the whole cache.
+	if !exists && q != nil && q.full() {
+		removed += c.makeRoomInQuotaLocked(q)
+	}
+	if !exists && c.maxItems > 0 && len(c.items) >= c.maxItems {
+		c.evictLocked(c.maxItems-1, removed)
+	}
+	delete(c.tombstones, k)
+
+	if it, ok := c.items[k]; ok {
+		now := c.clock()
+		c.setValueLocked(it, val, compressed)
+		c.setExpiry(it, now, expiry)
+		it.createdAt, it.lastAccess = now.UnixNano(), now.UnixNano()
+		c.touchQuotaLocked(it)
+		if c.policy == EvictSegmentedLRU {
+			c.promoteLocked(it)
+		} else {
+			c.order.moveToFront(it)
+		}
+		c.shrinkLocked()
+		return true
+	}
+
+	now := c.clock()
+	it := &item{key: k, heapIndex: -1, cost: defaultCost, createdAt: now.UnixNano(), lastAccess: now.UnixNano()}
+	c.cost += it.cost
+	c.setValueLocked(it, val, compressed)
+	c.setExpiry(it, now, expiry)
+	c.items[k] = it
+	atomic.AddInt64(&c.size, 1)
+	if b := c.bloomFilter(); b != nil {
+		b.add(k)
+	}
+	c.addToQuotaLocked(it, q)
+	if len(c.items) > c.peakItems {
+		c.peakItems = len(c.items)
+	}
+	c.insertColdLocked(it)
+	c.shrinkLocked()
+	return true
+}
+
+// setValueLocked replaces the stored value of it, keeping the byte count in
+// step. The caller must hold the write lock.
+func (c *Cache) setValueLocked(it *item, val []byte, compressed bool) {
+	c.bytes += int64(len(val) - len(it.val))
+	it.val, it.compressed = val, compressed
+	c.version++
+	it.version = c.version
+	c.queueWatchLocked(EventSet, it)
+}
+
+// removeLocked deletes k from the cache and queues the eviction callback for
+// it. The caller must hold the write lock.
+func (c *Cache) removeLocked(k string, reason EvictionReason) {
+	it, ok := c.items[k]
+	if !ok {
+		return
+	}
+	delete(c.items, k)
+	atomic.AddInt64(&c.size, -1)
+	if b := c.bloomFilter(); b != nil {
+		b.remove(k)
+	}
+	c.unlinkLocked(it)
+	c.expiries.remove(it)
+	c.untagLocked(it)
+	c.bytes -= int64(len(it.val))
+	c.cost -= it.cost
+	c.removeFromQuotaLocked(it)
+	if reason == ReasonExpired {
+		c.queueWatchLocked(EventExpire, it)
+	} else {
+		c.queueWatchLocked(EventDelete, it)
+	}
+	if reason != ReasonDeleted {
+		atomic.AddUint64(&c.evictions, 1)
+	}
+	if reason == ReasonCapacity {
+		c.capacityEvicted++
+	}
+	if c.onEvicted != nil || c.expirations != nil {
+		c.evicted = append(c.evicted, evictedItem{it, reason})
+	}
+}
+
+// deleteIfExpired removes k only if it is still expired once the write lock
+// is held, so a concurrent Set of a fresh value is never lost.
+func (c *Cache) deleteIfExpired(k string) {
+	c.mu.Lock()
+	defer c.unlock()
+	if v, ok := c.items[k]; ok && v.expired(c.clock().UnixNano()) {
+		c.removeLocked(k, ReasonExpired)
+	}
+}
+
+// SaveAndExit puts the cache in read-only mode and, with
+// WithPersistencePath, saves its live items so the file matches what the
+// cache holds from then on. Calling it again has no further effect on the
+// cache.
+func (c *Cache) SaveAndExit(k string) error {
+	atomic.StoreInt32(&c.readOnly, 1)
+	return c.persist()
+}
+
+// Resume leaves read-only mode so writes are accepted again, first applying
+// any writes queued by WithReadOnlyQueue.
+func (c *Cache) Resume() {
+	c.resume()
+}
+
+// isReadOnly reports whether writes are rejected, logging the rejection if
+// they are. It must only be called by writers.
+func (c *Cache) isReadOnly() bool {
+	if atomic.LoadInt32(&c.readOnly) == 0 {
+		return false
+	}
+	if c.logger != nil {
+		c.logRated(&c.readOnlyLog, "read_only_rejected", 1)
+	}
+	return true
+}
+
+// DeleteExpired removes all expired items, and idle items if an idle timeout
+// is set, now instead of waiting for the janitor.
+func (c *Cache) DeleteExpired() {
+	c.mu.Lock()
+	defer c.unlock()
+	c.deleteExpiredLocked(c.clock().UnixNano())
+}
+
+// deleteExpiredLocked implements DeleteExpired, removing the items expired
+// at now, and returns how many expired and idle items it removed. The caller
+// must hold the write lock.
+func (c *Cache) deleteExpiredLocked(now int64) (expired, idle int) {
+	n := len(c.items)
+	c.removeExpiredLocked(now)
+	expired = n - len(c.items)
+	c.removeIdleLocked()
+	idle = n - expired - len(c.items)
+	c.removeTombstonesLocked()
+	return expired, idle
+}
+
+// removeIdleLocked removes items that have not been read or written within
+// the idle timeout. The caller must hold the write lock.
+func (c *Cache) removeIdleLocked() {
+	if c.idleTimeout <= 0 {
+		return
+	}
+	cutoff := c.clock().Add(-c.idleTimeout).UnixNano()
+	for k, it := range c.items {
+		if atomic.LoadInt64(&it.lastAccess) < cutoff {
+			c.removeLocked(k, ReasonIdle)
+		}
+	}
+}
+
+// cleanupLocked removes expired items to make room for a write, at most the
+// eviction batch size of them, and returns how many it removed. The caller
+// must hold the write lock.
+func (c *Cache) cleanupLocked() int {
+	n := len(c.items)
+	if c.evictBatch > 0 {
+		c.reapLocked(c.evictBatch)
+	} else {
+		c.removeExpiredLocked(c.clock().UnixNano())
+	}
+	return n - len(c.items)
+}
+
+// removeExpiredLocked removes the items expired at now. The caller must hold
+// the write lock.
+func (c *Cache) removeExpiredLocked(now int64) {
+	for it := c.expiries.peek(); it != nil && it.expired(now); it = c.expiries.peek() {
+		c.removeLocked(it.key, ReasonExpired)
+	}
+}
+
+// encode returns the stored form of v, compressing it if compression is
+// enabled and v is large enough, then encrypting it if encryption is
+// enabled. It fails with ErrValueTooLarge if v is longer than the
+// WithMaxValueSize limit; writers call it before taking the lock, so
+// oversized values are rejected cheaply.
+func (c *Cache) encode(v string) ([]byte, bool, error) {
+	if c.maxValueSize > 0 && len(v) > c.maxValueSize {
+		return nil, false, ErrValueTooLarge
+	}
+	return c.seal([]byte(v))
+}
+
+// encodeBytes is encode for a byte slice. The result never shares memory
+// with v, so the caller may reuse it.
+func (c *Cache) encodeBytes(v []byte) ([]byte, bool, error) {
+	if c.maxValueSize > 0 && len(v) > c.maxValueSize {
+		return nil, false, ErrValueTooLarge
+	}
+	return c.seal(append([]byte(nil), v...))
+}
+
+// seal compresses and encrypts val as configured.
+func (c *Cache) seal(val []byte) ([]byte, bool, error) {
+	if c.aeadErr != nil {
+		return nil, false, c.aeadErr
+	}
+	compressed := false
+	if c.compressMin > 0 && len(val) >= c.compressMin {
+		var err error
+		if val, err = compress(val); err != nil {
+			return nil, false, err
+		}
+		compressed = true
+	}
+	if c.aead != nil {
+		var err error
+		if val, err = c.encrypt(val); err != nil {
+			return nil, false, err
+		}
+	}
+	return val, compressed, nil
+}
+
+// decode reverses encode.
+func (c *Cache) decode(val []byte, compressed bool) (string, error) {
+	b, err := c.open(val, compressed)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// decodeBytes reverses encodeBytes. The result never shares memory with
+// val, so the caller may modify it.
+func (c *Cache) decodeBytes(val []byte, compressed bool) ([]byte, error) {
+	b, err := c.open(val, compressed)
+	if err != nil {
+		return nil, err
+	}
+	if c.aead == nil && !compressed {
+		b = append([]byte(nil), b...)
+	}
+	return b, nil
+}
+
+// open reverses seal. With neither encryption nor compression it returns
+// val itself.
+func (c *Cache) open(val []byte, compressed bool) ([]byte, error) {
+	if c.aead != nil {
+		var err error
+		if val, err = c.decrypt(val); err != nil {
+			return nil, err
+		}
+	}
+	if !compressed {
+		return val, nil
+	}
+	return decompress(val)
+}
+
+func compress(v []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(v); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(val []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(val))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	return io.ReadAll(gz)
+}
+
+// PauseJanitor stops the janitor from sweeping until ResumeJanitor is
+// called. While paused it keeps waking on its interval but does no work.
+func (c *Cache) PauseJanitor() {
+	atomic.StoreInt32(&c.janitorPaused, 1)
+}
+
+// ResumeJanitor lets a paused janitor sweep again.
+func (c *Cache) ResumeJanitor() {
+	atomic.StoreInt32(&c.janitorPaused, 0)
+}
+
+// Close stops the janitor and, with WithPersistencePath, saves the live
+// items to disk. It is safe to call Close more than once; only the first
+// call saves.
+func (c *Cache) Close() error {
+	var err error
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		err = c.persist()
+	})
+	return err
+}
+
+// defaultJanitorInterval is used when neither a janitor interval nor a
+// positive default expiry is configured.
+const defaultJanitorInterval = time.Minute
+
+func (c *Cache) janitor() {
+	interval := c.janitorInterval
+	if interval <= 0 {
+		interval = c.defaultExpiry * 2
+	}
+	if interval <= 0 {
+		interval = defaultJanitorInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
 	for {
-		<-time.After(c.defaultExpiry * 2)
-		c.cleanup()
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			if atomic.LoadInt32(&c.janitorPaused) == 0 {
+				c.sweep()
+			}
+		}
 	}
 }
 
@@ -162,7 +1595,7 @@ func main() {
 	start := time.Now()
 	ch := make(chan bool)
 	fmt.Println("Start writing to the cache")
-	go writeRand(c, ch, maxItems)
+	go writeRand(c, ch)
 	<-time.After(time.Millisecond)
 	fmt.Println("Start reading from the cache")
 	go readRand(c, ch)
@@ -170,12 +1603,12 @@ func main() {
 	<-ch
 	<-ch
 
-	fmt.Printf("%d items remained in the cache. \n", len(c.items))
+	fmt.Printf("%d items remained in the cache. \n", c.Len())
 	fmt.Printf("Total exec time: %d milisecond. \n", time.Since(start).Milliseconds())
 
 }
 
-func writeRand(c *Cache, ch chan<- bool, maxItems int) {
+func writeRand(c *Cache, ch chan<- bool) {
 	wg := new(sync.WaitGroup)
 	seed := rand.NewSource(time.Now().UnixNano())
 	rnd := rand.New(seed)
@@ -189,7 +1622,7 @@ func writeRand(c *Cache, ch chan<- bool, maxItems int) {
 			r := fmt.Sprintf("%d", rnd.Intn(20*1000))
 			m := time.Duration(rnd.Int63n(int64(5 * time.Minute)))
 			mu.RUnlock()
-			c.Set(r, r, maxItems, m)
+			c.Set(r, r, m)
 			wg.Done()
 		}()
 	}
@@ -0,0 +1,72 @@
+package main
+
+import "time"
+
+// Merge copies the live items of other into c, each keeping the time it has
+// left to live. For a key live in both caches, conflict is called with c's
+// value a and other's value b and its result is stored, with the lifetime
+// of other's item; a nil conflict keeps other's value. conflict runs with
+// c's lock held, so it must not use c. Merge does nothing in read-only mode,
+// and c's capacity limits apply as for Set.
+func (c *Cache) Merge(other *Cache, conflict func(key, a, b string) string) {
+	if c.isReadOnly() {
+		return
+	}
+
+	type incoming struct {
+		key        string
+		value      string
+		val        []byte
+		compressed bool
+		ttl        time.Duration
+	}
+	var entries []incoming
+	other.mu.RLock()
+	now := other.clock().UnixNano()
+	for k, it := range other.items {
+		if it.expired(now) {
+			continue
+		}
+		v, err := other.decode(it.val, it.compressed)
+		if err != nil {
+			continue
+		}
+		ttl := NoExpiration
+		if it.expiry != 0 {
+			// Zero would mean the default expiry, so skip items
+			// expiring this very instant.
+			if ttl = time.Duration(it.expiry - now); ttl <= 0 {
+				continue
+			}
+		}
+		entries = append(entries, incoming{key: k, value: v, ttl: ttl})
+	}
+	other.mu.RUnlock()
+
+	for i := range entries {
+		e := &entries[i]
+		var err error
+		if e.val, e.compressed, err = c.encode(e.value); err != nil {
+			e.val = nil
+		}
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	for _, e := range entries {
+		if e.val == nil {
+			continue
+		}
+		val, compressed := e.val, e.compressed
+		if it, err := c.liveLocked(e.key); err == nil && conflict != nil {
+			a, err := c.decode(it.val, it.compressed)
+			if err != nil {
+				continue
+			}
+			if val, compressed, err = c.encode(conflict(e.key, a, e.value)); err != nil {
+				continue
+			}
+		}
+		c.setLocked(e.key, val, compressed, e.ttl)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeDisjoint(t *testing.T) {
+	c := NewCache(time.Minute)
+	other := NewCache(time.Minute)
+	c.Set("a", "1", 0)
+	other.Set("b", "2", 0)
+	other.Set("c", "3", 0)
+
+	c.Merge(other, nil)
+	want := map[string]string{"a": "1", "b": "2", "c": "3"}
+	for k, v := range want {
+		if got, ok := c.Get(k); !ok || got != v {
+			t.Errorf("Get(%s) = %q, %v; want %q, true", k, got, ok, v)
+		}
+	}
+	if n := other.Size(); n != 2 {
+		t.Fatalf("other has %d items after Merge, want 2", n)
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	c := NewCache(time.Minute)
+	other := NewCache(time.Minute)
+	c.Set("shared", "a", 0)
+	c.Set("mine", "m", 0)
+	other.Set("shared", "b", 0)
+
+	var calls int
+	c.Merge(other, func(key, a, b string) string {
+		calls++
+		return a + "+" + b
+	})
+	if v, _ := c.Get("shared"); v != "a+b" || calls != 1 {
+		t.Fatalf("Get(shared) = %q after %d conflict calls; want \"a+b\" after 1", v, calls)
+	}
+
+	c.Merge(other, nil)
+	if v, _ := c.Get("shared"); v != "b" {
+		t.Fatalf("Get(shared) with a nil conflict = %q, want other's \"b\"", v)
+	}
+}
+
+func TestMergeKeepsTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithDefaultExpiry(time.Minute))
+	other := New(WithClock(clock.Now))
+	other.Set("short", "1", 10*time.Second)
+	other.Set("forever", "2", NoExpiration)
+	other.Set("expired", "3", time.Second)
+	clock.Advance(4 * time.Second)
+
+	c.Merge(other, nil)
+	if ttl, ok := c.TTL("short"); !ok || ttl != 6*time.Second {
+		t.Errorf("TTL(short) = %v, %v; want 6s, true", ttl, ok)
+	}
+	if ttl, ok := c.TTL("forever"); !ok || ttl != NoExpiration {
+		t.Errorf("TTL(forever) = %v, %v; want NoExpiration, true", ttl, ok)
+	}
+	if c.Has("expired") {
+		t.Error("Merge copied an expired item")
+	}
+
+	c.SaveAndExit("")
+	other.Set("late", "4", 0)
+	c.Merge(other, nil)
+	if c.Has("late") {
+		t.Fatal("Merge wrote to a read-only cache")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "sync/atomic"
+
+// MetricType is the kind of a metric, named as in the Prometheus exposition
+// format.
+type MetricType string
+
+const (
+	// CounterMetric only ever increases, apart from ResetStats.
+	CounterMetric MetricType = "counter"
+	// GaugeMetric can go up and down.
+	GaugeMetric MetricType = "gauge"
+)
+
+// Desc describes a metric reported by a Collector.
+type Desc struct {
+	Name string
+	Help string
+	Type MetricType
+}
+
+// Metric is a single sample reported by a Collector.
+type Metric struct {
+	Desc
+	Value float64
+}
+
+// Collector reports a cache's counters in the shape of a Prometheus
+// collector without depending on the client library: wrapping Describe and
+// Collect in a prometheus.Collector only takes converting each Desc and
+// Metric. It holds no state, so it may be registered once and collected
+// concurrently.
+type Collector struct {
+	c *Cache
+}
+
+var metricDescs = [...]Desc{
+	{"gocache_hits_total", "Number of reads that found a live item.", CounterMetric},
+	{"gocache_misses_total", "Number of reads that found no live item.", CounterMetric},
+	{"gocache_evictions_total", "Number of items removed by expiry or eviction.", CounterMetric},
+	{"gocache_items", "Number of items in the cache.", GaugeMetric},
+}
+
+// Collector returns a metrics collector for c.
+func (c *Cache) Collector() *Collector {
+	return &Collector{c: c}
+}
+
+// Describe sends the description of every metric Collect reports.
+func (m *Collector) Describe(ch chan<- Desc) {
+	for _, d := range metricDescs {
+		ch <- d
+	}
+}
+
+// Collect sends the current value of every metric.
+func (m *Collector) Collect(ch chan<- Metric) {
+	values := [len(metricDescs)]float64{
+		float64(atomic.LoadUint64(&m.c.hits)),
+		float64(atomic.LoadUint64(&m.c.misses)),
+		float64(atomic.LoadUint64(&m.c.evictions)),
+		float64(m.c.Size()),
+	}
+	for i, d := range metricDescs {
+		ch <- Metric{Desc: d, Value: values[i]}
+	}
+}
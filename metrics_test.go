@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollector(t *testing.T) {
+	c := New(WithMaxItems(2))
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", time.Hour)
+	c.Set("c", "3", time.Hour) // evicts one item
+	c.Get("c")
+	c.Get("missing")
+
+	m := c.Collector()
+	descs := make(chan Desc, len(metricDescs))
+	m.Describe(descs)
+	close(descs)
+	described := make(map[string]MetricType)
+	for d := range descs {
+		described[d.Name] = d.Type
+	}
+
+	metrics := make(chan Metric, len(metricDescs))
+	m.Collect(metrics)
+	close(metrics)
+	got := make(map[string]float64)
+	for s := range metrics {
+		if described[s.Name] != s.Type {
+			t.Errorf("%s collected as %s but described as %s", s.Name, s.Type, described[s.Name])
+		}
+		got[s.Name] = s.Value
+	}
+
+	want := map[string]float64{
+		"gocache_hits_total":      1,
+		"gocache_misses_total":    1,
+		"gocache_evictions_total": 1,
+		"gocache_items":           2,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("collected %v, want %v", got, want)
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+}
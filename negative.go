@@ -0,0 +1,55 @@
+package main
+
+import "time"
+
+// SetMissing records that k is known to be absent for ttl, removing any value
+// stored under k. While the tombstone is live GetOrMiss reports k as a cached
+// miss and GetOrLoad does not call its loader. Storing a value under k clears
+// the tombstone. ttl follows the same rules as a Set expiry.
+func (c *Cache) SetMissing(k string, ttl time.Duration) {
+	if c.isReadOnly() {
+		return
+	}
+
+	ttl = c.lifetime(ttl)
+
+	c.mu.Lock()
+	defer c.unlock()
+	c.removeLocked(k, ReasonDeleted)
+	var expiry int64
+	if ttl > 0 {
+		expiry = c.clock().Add(ttl).UnixNano()
+	}
+	if c.tombstones == nil {
+		c.tombstones = make(map[string]int64)
+	}
+	c.tombstones[k] = expiry
+}
+
+// GetOrMiss is like Get but also reports, when k is not found, whether that
+// is a cached miss recorded by SetMissing rather than a key never seen.
+func (c *Cache) GetOrMiss(k string) (value string, found bool, cachedMiss bool) {
+	if v, ok := c.Get(k); ok {
+		return v, true, false
+	}
+	return "", false, c.isMissing(k)
+}
+
+// isMissing reports whether k has a live tombstone.
+func (c *Cache) isMissing(k string) bool {
+	c.mu.RLock()
+	expiry, ok := c.tombstones[k]
+	c.mu.RUnlock()
+	return ok && (expiry == 0 || c.clock().UnixNano() <= expiry)
+}
+
+// removeTombstonesLocked drops expired tombstones. The caller must hold the
+// write lock.
+func (c *Cache) removeTombstonesLocked() {
+	now := c.clock().UnixNano()
+	for k, expiry := range c.tombstones {
+		if expiry != 0 && now > expiry {
+			delete(c.tombstones, k)
+		}
+	}
+}
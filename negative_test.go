@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetMissing(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	c.Set("gone", "old", time.Hour)
+	c.SetMissing("gone", time.Second)
+
+	if v, found, miss := c.GetOrMiss("gone"); found || !miss || v != "" {
+		t.Fatalf("GetOrMiss(gone) = %q, %v, %v; want \"\", false, true", v, found, miss)
+	}
+	if _, found, miss := c.GetOrMiss("never"); found || miss {
+		t.Fatalf("GetOrMiss(never) = _, %v, %v; want false, false", found, miss)
+	}
+
+	calls := 0
+	loader := func() (string, error) {
+		calls++
+		return "loaded", nil
+	}
+	if _, err := c.GetOrLoad("gone", time.Hour, loader); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetOrLoad with a live tombstone: err = %v, want ErrNotFound", err)
+	}
+	if calls != 0 {
+		t.Fatalf("loader called %d times while the tombstone was live, want 0", calls)
+	}
+
+	clock.Advance(2 * time.Second)
+	if v, err := c.GetOrLoad("gone", time.Hour, loader); err != nil || v != "loaded" {
+		t.Fatalf("GetOrLoad after the tombstone expired = %q, %v; want \"loaded\", nil", v, err)
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times, want 1", calls)
+	}
+}
+
+func TestSetClearsTombstone(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.SetMissing("k", time.Hour)
+	c.Set("k", "v", 0)
+
+	if v, found, miss := c.GetOrMiss("k"); !found || miss || v != "v" {
+		t.Fatalf("GetOrMiss(k) = %q, %v, %v; want \"v\", true, false", v, found, miss)
+	}
+	c.Delete("k")
+	if _, _, miss := c.GetOrMiss("k"); miss {
+		t.Fatal("tombstone came back after Set and Delete")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import "time"
+
+// Option configures a Cache at construction time.
+type Option func(*Cache)
+
+// New creates a cache configured by opts. A janitor is started if
+// WithJanitorInterval is given; stop it with Close.
+func New(opts ...Option) *Cache {
+	c := newCache(opts)
+	if c.janitorInterval > 0 {
+		go c.janitor()
+	}
+	return c
+}
+
+// WithDefaultExpiry sets the expiry used for DefaultExpiration. Zero or a
+// negative duration means such items never expire.
+func WithDefaultExpiry(d time.Duration) Option {
+	return func(c *Cache) {
+		c.defaultExpiry = d
+	}
+}
+
+// WithMaxItems limits the number of items in the cache. Zero means no limit.
+func WithMaxItems(n int) Option {
+	return func(c *Cache) {
+		c.maxItems = n
+	}
+}
+
+// WithEvictionPolicy sets the policy used to evict live items when the cache
+// is full.
+func WithEvictionPolicy(p EvictionPolicy) Option {
+	return func(c *Cache) {
+		c.policy = p
+	}
+}
+
+// WithCompression gzips values of at least minBytes bytes. Smaller values are
+// stored as is.
+func WithCompression(minBytes int) Option {
+	return func(c *Cache) {
+		c.compressMin = minBytes
+	}
+}
+
+// WithSlidingExpiration makes every successful read restart the item's
+// lifetime, so items only expire once they stop being read.
+func WithSlidingExpiration() Option {
+	return func(c *Cache) {
+		c.sliding = true
+	}
+}
+
+// WithMaxBytes limits the total size of stored values to n bytes. When a
+// write goes over the limit the least recently written items are evicted,
+// or the least recently used ones under EvictLRU.
+func WithMaxBytes(n int64) Option {
+	return func(c *Cache) {
+		c.maxBytes = n
+	}
+}
+
+// WithJanitorInterval sets how often the janitor removes expired items,
+// independently of the default expiry.
+func WithJanitorInterval(d time.Duration) Option {
+	return func(c *Cache) {
+		c.janitorInterval = d
+	}
+}
+
+// WithClock makes the cache read the current time from clock instead of
+// time.Now, which lets tests control expiry.
+func WithClock(clock func() time.Time) Option {
+	return func(c *Cache) {
+		c.clock = clock
+	}
+}
+
+// WithIdleTimeout makes the janitor also remove items that have not been
+// read or written for d, even if they have not expired.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(c *Cache) {
+		c.idleTimeout = d
+	}
+}
+
+// WithMaxValueSize makes writes reject values longer than n bytes instead of
+// storing them; TrySet reports ErrValueTooLarge. Zero means no limit.
+func WithMaxValueSize(n int) Option {
+	return func(c *Cache) {
+		c.maxValueSize = n
+	}
+}
+
+// WithMinTTL sets the shortest lifetime an item may have. Writes asking for
+// less are stored with a lifetime of d instead, except TrySet, which rejects
+// them with ErrTTLTooShort. Never-expiring items are not affected.
+func WithMinTTL(d time.Duration) Option {
+	return func(c *Cache) {
+		c.minTTL = d
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		expiry time.Duration
+		max    int
+		policy EvictionPolicy
+	}{
+		{"defaults", nil, 0, 0, EvictRandom},
+		{"expiry", []Option{WithDefaultExpiry(time.Minute)}, time.Minute, 0, EvictRandom},
+		{"capacity and policy", []Option{WithMaxItems(100), WithEvictionPolicy(EvictLRU)}, 0, 100, EvictLRU},
+		{"last option wins", []Option{WithMaxItems(1), WithMaxItems(2)}, 0, 2, EvictRandom},
+	}
+	for _, tt := range tests {
+		c := New(tt.opts...)
+		if c.defaultExpiry != tt.expiry || c.maxItems != tt.max || c.policy != tt.policy {
+			t.Errorf("%s: got expiry %v, max %d, policy %d; want %v, %d, %d",
+				tt.name, c.defaultExpiry, c.maxItems, c.policy, tt.expiry, tt.max, tt.policy)
+		}
+		c.Close()
+	}
+}
+
+func TestNewStartsJanitorOnlyWhenAsked(t *testing.T) {
+	before := runtime.NumGoroutine()
+	c := New(WithDefaultExpiry(time.Minute))
+	defer c.Close()
+	if n := runtime.NumGoroutine(); n > before {
+		t.Fatalf("New without a janitor interval started %d goroutines", n-before)
+	}
+
+	clock := newFakeClock()
+	j := New(WithJanitorInterval(time.Millisecond), WithClock(clock.Now))
+	defer j.Close()
+	j.Set("a", "1", time.Second)
+	clock.Advance(time.Minute)
+
+	deadline := time.Now().Add(time.Second)
+	for j.ItemCount() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("janitor started by New did not reap the expired item")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLegacyConstructorsMatchNew(t *testing.T) {
+	c := NewCacheWithJanitor(time.Minute, 50, WithEvictionPolicy(EvictLFU))
+	defer c.Close()
+	if c.defaultExpiry != time.Minute || c.maxItems != 50 || c.policy != EvictLFU {
+		t.Fatalf("NewCacheWithJanitor: got expiry %v, max %d, policy %d", c.defaultExpiry, c.maxItems, c.policy)
+	}
+
+	// The limit must be in place before the sketch is sized and the
+	// persistence file is loaded.
+	lfu := NewCacheWithJanitor(time.Minute, 5000, WithAdmissionPolicy(TinyLFU))
+	defer lfu.Close()
+	if want := New(WithMaxItems(5000), WithAdmissionPolicy(TinyLFU)).sketch.mask; lfu.sketch.mask != want {
+		t.Fatalf("NewCacheWithJanitor sized the sketch with mask %d, want %d", lfu.sketch.mask, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "cache.gob")
+	saved := New(WithPersistencePath(path))
+	for i := 0; i < 10; i++ {
+		saved.Set(strconv.Itoa(i), "v", time.Hour)
+	}
+	if err := saved.Close(); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewCacheWithJanitor(time.Minute, 3, WithPersistencePath(path))
+	defer restored.Close()
+	if n := restored.Len(); n != 3 {
+		t.Fatalf("NewCacheWithJanitor loaded %d items with a limit of 3", n)
+	}
+}
+
+func TestWithMaxValueSize(t *testing.T) {
+	c := New(WithMaxValueSize(4))
+
+	if err := c.TrySet("under", "abcd", 0); err != nil {
+		t.Fatalf("TrySet of a 4-byte value: %v", err)
+	}
+	if err := c.TrySet("over", "abcde", 0); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("TrySet of a 5-byte value: err = %v, want ErrValueTooLarge", err)
+	}
+	c.Set("over", "abcde", 0)
+	if _, ok := c.Get("over"); ok {
+		t.Fatal("Set stored a value over the limit")
+	}
+	if v, ok := c.Get("under"); !ok || v != "abcd" {
+		t.Fatalf("Get(under) = %q, %v; want \"abcd\", true", v, ok)
+	}
+}
+
+func TestWithMinTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithMinTTL(time.Second), WithDefaultExpiry(time.Millisecond))
+
+	c.Set("ns", "v", time.Nanosecond)
+	c.Set("default", "v", DefaultExpiration)
+	c.Set("long", "v", time.Minute)
+	c.Set("forever", "v", NoExpiration)
+	c.SetWithTags("tagged", "v", time.Millisecond, "t")
+	for k, want := range map[string]time.Duration{
+		"ns":      time.Second,
+		"default": time.Second,
+		"long":    time.Minute,
+		"forever": NoExpiration,
+		"tagged":  time.Second,
+	} {
+		if ttl, ok := c.TTL(k); !ok || ttl != want {
+			t.Errorf("TTL(%s) = %v, %v; want %v, true", k, ttl, ok, want)
+		}
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if !c.Has("ns") {
+		t.Fatal("an item with a clamped TTL expired before the minimum")
+	}
+
+	if err := c.TrySet("short", "v", time.Millisecond); !errors.Is(err, ErrTTLTooShort) {
+		t.Fatalf("TrySet with a short expiry = %v, want ErrTTLTooShort", err)
+	}
+	if c.Has("short") {
+		t.Fatal("TrySet stored an item it rejected")
+	}
+	if err := c.TrySet("ok", "v", time.Second); err != nil {
+		t.Fatalf("TrySet at the minimum = %v, want nil", err)
+	}
+}
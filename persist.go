@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is bumped whenever the on-disk format changes.
+const snapshotVersion = 1
+
+type snapshot struct {
+	Version int
+	Items   []snapshotItem
+}
+
+type snapshotItem struct {
+	Key   string
+	Value string
+	TTL   time.Duration // remaining lifetime, or NoExpiration
+}
+
+// WithPersistencePath makes the cache survive restarts through the file at
+// path: the cache starts with the items saved there, and Close and
+// SaveAndExit save its live items back with their remaining lifetimes. A
+// missing file starts the cache empty; one that cannot be read is reported
+// to the logger as "load_failed" and does not stop the cache from starting.
+func WithPersistencePath(path string) Option {
+	return func(c *Cache) {
+		c.persistPath = path
+	}
+}
+
+// WithAutosave makes a background goroutine save the cache to path every
+// interval, as SaveToFile does, until Close is called. Failed saves are
+// reported to the logger as "autosave_failed". Reload the file with
+// LoadFromFile, or give the same path to WithPersistencePath.
+func WithAutosave(path string, interval time.Duration) Option {
+	return func(c *Cache) {
+		c.autosavePath = path
+		c.autosaveInterval = interval
+	}
+}
+
+func (c *Cache) autosave() {
+	t := time.NewTicker(c.autosaveInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			err := c.SaveToFile(c.autosavePath)
+			if err != nil && c.logger != nil {
+				c.logger("autosave_failed", map[string]any{"path": c.autosavePath, "error": err})
+			}
+		}
+	}
+}
+
+// restore loads the persistence file, if one is configured, into a cache
+// that has just been created.
+func (c *Cache) restore() {
+	if c.persistPath == "" {
+		return
+	}
+	err := c.loadFile(c.persistPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) && c.logger != nil {
+		c.logger("load_failed", map[string]any{"path": c.persistPath, "error": err})
+	}
+}
+
+// persist saves the cache to the persistence file, if one is configured.
+func (c *Cache) persist() error {
+	if c.persistPath == "" {
+		return nil
+	}
+	return c.SaveToFile(c.persistPath)
+}
+
+// SaveToFile writes all live items and their remaining lifetimes to path.
+// The file is written under a temporary name and renamed into place, so path
+// always holds a complete snapshot, even if the process dies mid-write.
+func (c *Cache) SaveToFile(path string) error {
+	snap, err := c.snapshot()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+	if err := gob.NewEncoder(f).Encode(snap); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadFromFile creates a cache with default expiry ed from a file written by
+// SaveToFile. Each item keeps the lifetime it had left when it was saved.
+func LoadFromFile(path string, ed time.Duration) (*Cache, error) {
+	c := NewCache(ed)
+	if err := c.loadFile(path); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cache) snapshot() (*snapshot, error) {
+	type entry struct {
+		key        string
+		val        []byte
+		compressed bool
+		expiry     int64
+	}
+
+	c.mu.RLock()
+	now := c.clock().UnixNano()
+	entries := make([]entry, 0, len(c.items))
+	for k, it := range c.items {
+		if !it.expired(now) {
+			entries = append(entries, entry{k, it.val, it.compressed, it.expiry})
+		}
+	}
+	c.mu.RUnlock()
+
+	snap := &snapshot{
+		Version: snapshotVersion,
+		Items:   make([]snapshotItem, 0, len(entries)),
+	}
+	for _, e := range entries {
+		v, err := c.decode(e.val, e.compressed)
+		if err != nil {
+			return nil, err
+		}
+		ttl := NoExpiration
+		if e.expiry != 0 {
+			ttl = time.Duration(e.expiry - now)
+		}
+		snap.Items = append(snap.Items, snapshotItem{Key: e.key, Value: v, TTL: ttl})
+	}
+	return snap, nil
+}
+
+func (c *Cache) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil {
+		return err
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("cache: unsupported snapshot version %d", snap.Version)
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	for _, it := range snap.Items {
+		val, compressed, err := c.encode(it.Value)
+		if err != nil {
+			return err
+		}
+		c.setLocked(it.Key, val, compressed, it.TTL)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.gob")
+
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", NoExpiration)
+	c.Set("expired", "3", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if err := c.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile: %v", err)
+	}
+
+	loaded, err := LoadFromFile(path, time.Minute)
+	if err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+
+	if v, ok := loaded.Get("a"); !ok || v != "1" {
+		t.Fatalf("Get(a) = %q, %v; want \"1\", true", v, ok)
+	}
+	if ttl, _ := loaded.TTL("a"); ttl > time.Hour || ttl < time.Hour-time.Second {
+		t.Fatalf("TTL(a) = %v, want about 1h", ttl)
+	}
+	if ttl, ok := loaded.TTL("b"); !ok || ttl != NoExpiration {
+		t.Fatalf("TTL(b) = %v, %v; want NoExpiration, true", ttl, ok)
+	}
+	if loaded.Has("expired") {
+		t.Fatal("an expired item was saved")
+	}
+}
+
+func TestLoadFileRejectsUnknownVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.gob")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gob.NewEncoder(f).Encode(snapshot{Version: snapshotVersion + 1}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if _, err := LoadFromFile(path, time.Minute); err == nil {
+		t.Fatal("LoadFromFile accepted a snapshot with an unknown version")
+	}
+}
+
+func TestPersistencePathSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.gob")
+
+	c := New(WithPersistencePath(path), WithJanitorInterval(time.Minute))
+	if c.Len() != 0 {
+		t.Fatalf("new cache without a file holds %d items", c.Len())
+	}
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", NoExpiration)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	c.Set("after-close", "3", time.Hour)
+	if err := c.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	restored := New(WithPersistencePath(path))
+	defer restored.Close()
+	if v, ok := restored.Get("a"); !ok || v != "1" {
+		t.Fatalf("Get(a) = %q, %v; want \"1\", true", v, ok)
+	}
+	if ttl, _ := restored.TTL("a"); ttl > time.Hour || ttl < time.Hour-time.Second {
+		t.Fatalf("TTL(a) = %v, want about 1h", ttl)
+	}
+	if ttl, ok := restored.TTL("b"); !ok || ttl != NoExpiration {
+		t.Fatalf("TTL(b) = %v, %v; want NoExpiration, true", ttl, ok)
+	}
+	if restored.Has("after-close") {
+		t.Fatal("a second Close saved the cache again")
+	}
+}
+
+func TestSaveAndExitPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.gob")
+
+	c := New(WithPersistencePath(path))
+	c.Set("k", "v", time.Hour)
+	if err := c.SaveAndExit(""); err != nil {
+		t.Fatalf("SaveAndExit: %v", err)
+	}
+
+	if v, ok := New(WithPersistencePath(path)).Get("k"); !ok || v != "v" {
+		t.Fatalf("Get(k) after SaveAndExit = %q, %v; want \"v\", true", v, ok)
+	}
+}
+
+func TestPersistencePathUnreadableFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.gob")
+	if err := os.WriteFile(path, []byte("not a snapshot"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	c := New(WithPersistencePath(path), WithLogger(func(event string, fields map[string]any) {
+		events = append(events, event)
+	}))
+	if c.Len() != 0 || len(events) != 1 || events[0] != "load_failed" {
+		t.Fatalf("cache holds %d items and logged %v; want 0 and [load_failed]", c.Len(), events)
+	}
+}
+
+func TestAutosave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.gob")
+
+	c := New(WithAutosave(path, 10*time.Millisecond))
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", NoExpiration)
+
+	// Wait for an autosave, then "crash" by dropping c without Close.
+	var loaded *Cache
+	for deadline := time.Now().Add(2 * time.Second); loaded == nil || loaded.Len() != 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("no autosave with both items within 2s")
+		}
+		time.Sleep(5 * time.Millisecond)
+		loaded, _ = LoadFromFile(path, time.Minute)
+	}
+	if v, ok := loaded.Get("a"); !ok || v != "1" {
+		t.Fatalf("Get(a) = %q, %v; want \"1\", true", v, ok)
+	}
+	if ttl, ok := loaded.TTL("b"); !ok || ttl != NoExpiration {
+		t.Fatalf("TTL(b) = %v, %v; want NoExpiration, true", ttl, ok)
+	}
+
+	c.Close()
+	time.Sleep(20 * time.Millisecond) // let a save that was under way finish
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("autosave kept running after Close: Stat = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("autosave left %d files behind", len(entries))
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// queuedWrite is a Set made in read-only mode, waiting for Resume.
+type queuedWrite struct {
+	key        string
+	value      string // kept for the store
+	val        []byte
+	compressed bool
+	expiry     time.Duration
+}
+
+// WithReadOnlyQueue makes Set and TrySet in read-only mode queue up to max
+// writes instead of dropping them. Resume applies the queued writes in the
+// order they were made, before any write made after it, with expiries
+// counted from the Resume. Once the queue is full, TrySet reports ErrQueueFull
+// and Set drops the write. Other writes are still rejected in read-only
+// mode.
+func WithReadOnlyQueue(max int) Option {
+	return func(c *Cache) {
+		c.queueMax = max
+	}
+}
+
+// queueWrite queues a write of v under k if the cache is read-only and has
+// a write queue. It reports whether it took care of the write, with
+// ErrQueueFull if the queue had no room or ErrCapacity if v on its own does
+// not fit in the byte limit.
+func (c *Cache) queueWrite(k, v string, expiry time.Duration) (bool, error) {
+	if c.queueMax <= 0 || atomic.LoadInt32(&c.readOnly) == 0 {
+		return false, nil
+	}
+	val, compressed, err := c.encode(v)
+	if err != nil {
+		return true, err
+	}
+	// Rejected now, as it would be once applied, rather than dropped by
+	// Resume.
+	if c.maxBytes > 0 && int64(len(val)) > c.maxBytes {
+		return true, ErrCapacity
+	}
+
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+	// Resume may have replayed the queue since the check above.
+	if atomic.LoadInt32(&c.readOnly) == 0 {
+		return false, nil
+	}
+	if len(c.queued) >= c.queueMax {
+		return true, ErrQueueFull
+	}
+	c.queued = append(c.queued, queuedWrite{k, v, val, compressed, expiry})
+	return true, nil
+}
+
+// resume leaves read-only mode, applying the queued writes first under the
+// same lock so that no later write can come between them.
+func (c *Cache) resume() {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+	queued := c.queued
+	c.queued = nil
+
+	c.mu.Lock()
+	for _, w := range queued {
+		c.setLocked(w.key, w.val, w.compressed, w.expiry)
+	}
+	atomic.StoreInt32(&c.readOnly, 0)
+	c.unlock()
+
+	if c.store != nil {
+		for _, w := range queued {
+			c.store.Save(w.key, w.value, c.lifetime(w.expiry))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyQueue(t *testing.T) {
+	c := New(WithReadOnlyQueue(3))
+	c.Set("k", "before", time.Hour)
+	c.SaveAndExit("")
+
+	c.Set("k", "first", time.Hour)
+	if err := c.TrySet("other", "x", time.Hour); err != nil {
+		t.Fatalf("TrySet in read-only mode = %v, want it queued", err)
+	}
+	c.Set("k", "second", time.Hour)
+	if err := c.TrySet("overflow", "x", time.Hour); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("TrySet past the queue size = %v, want ErrQueueFull", err)
+	}
+	if v, _ := c.Get("k"); v != "before" {
+		t.Fatalf("Get(k) in read-only mode = %q, want the queued writes held back", v)
+	}
+	c.Delete("k")
+	if !c.Has("k") {
+		t.Fatal("Delete in read-only mode was applied")
+	}
+
+	c.Resume()
+	// Replayed in order, so the later write to k wins.
+	if v, _ := c.Get("k"); v != "second" {
+		t.Fatalf("Get(k) after Resume = %q, want \"second\"", v)
+	}
+	if !c.Has("other") || c.Has("overflow") {
+		t.Fatal("Resume did not replay exactly the queued writes")
+	}
+
+	c.Set("k", "third", time.Hour)
+	c.SaveAndExit("")
+	c.Resume()
+	if v, _ := c.Get("k"); v != "third" {
+		t.Fatalf("Get(k) = %q; a replayed write came back after a second Resume", v)
+	}
+}
+
+func TestReadOnlyQueueTooLarge(t *testing.T) {
+	c := New(WithReadOnlyQueue(3), WithMaxBytes(8))
+	c.SaveAndExit("")
+	if err := c.TrySet("big", "0123456789", time.Hour); !errors.Is(err, ErrCapacity) {
+		t.Fatalf("TrySet of a value over the byte limit = %v, want ErrCapacity", err)
+	}
+	if n := len(c.queued); n != 0 {
+		t.Fatalf("%d writes queued, want the oversized one rejected", n)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"container/list"
+	"strings"
+)
+
+// prefixQuota limits the number of keys under a prefix.
+type prefixQuota struct {
+	prefix string
+	max    int
+	items  *list.List // items under prefix, the next one to evict at the back
+}
+
+// WithPrefixQuota limits the keys starting with prefix to maxItems. A new key
+// under a full prefix evicts a key under the same prefix, not one from the
+// rest of the cache: the least recently written one, or the least recently
+// used one under EvictLRU. Quotas may be given for several prefixes; a key
+// matching more than one counts against the longest.
+func WithPrefixQuota(prefix string, maxItems int) Option {
+	return func(c *Cache) {
+		c.quotas = append(c.quotas, &prefixQuota{prefix: prefix, max: maxItems, items: list.New()})
+	}
+}
+
+// quotaFor returns the quota k counts against, or nil if it has none.
+func (c *Cache) quotaFor(k string) *prefixQuota {
+	var q *prefixQuota
+	for _, p := range c.quotas {
+		if strings.HasPrefix(k, p.prefix) && (q == nil || len(p.prefix) > len(q.prefix)) {
+			q = p
+		}
+	}
+	return q
+}
+
+// full reports whether a new key under q's prefix has to evict one first.
+func (q *prefixQuota) full() bool {
+	return q.items.Len() >= q.max
+}
+
+// makeRoomInQuotaLocked evicts keys under q's prefix until a new one fits
+// and returns how many it evicted. The caller must hold the write lock.
+func (c *Cache) makeRoomInQuotaLocked(q *prefixQuota) int {
+	n := 0
+	for ; q.full() && q.items.Len() > 0; n++ {
+		c.removeLocked(q.items.Back().Value.(*item).key, ReasonCapacity)
+	}
+	return n
+}
+
+// addToQuotaLocked counts the new item it against q, if it has a quota. The
+// caller must hold the write lock.
+func (c *Cache) addToQuotaLocked(it *item, q *prefixQuota) {
+	if q != nil {
+		it.quota, it.quotaElem = q, q.items.PushFront(it)
+	}
+}
+
+// touchQuotaLocked makes it the last key its quota would evict. The caller
+// must hold the write lock.
+func (c *Cache) touchQuotaLocked(it *item) {
+	if it.quota != nil {
+		it.quota.items.MoveToFront(it.quotaElem)
+	}
+}
+
+// removeFromQuotaLocked stops counting it against its quota. The caller
+// must hold the write lock.
+func (c *Cache) removeFromQuotaLocked(it *item) {
+	if it.quota != nil {
+		it.quota.items.Remove(it.quotaElem)
+		it.quota, it.quotaElem = nil, nil
+	}
+}
+
+// copyQuotas returns c's quotas with no keys counted.
+func (c *Cache) copyQuotas() []*prefixQuota {
+	if c.quotas == nil {
+		return nil
+	}
+	quotas := make([]*prefixQuota, len(c.quotas))
+	for i, q := range c.quotas {
+		quotas[i] = &prefixQuota{prefix: q.prefix, max: q.max, items: list.New()}
+	}
+	return quotas
+}
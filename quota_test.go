@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPrefixQuota(t *testing.T) {
+	c := New(WithMaxItems(100), WithPrefixQuota("tenant:a:", 10))
+	for i := 0; i < 20; i++ {
+		c.Set(fmt.Sprintf("tenant:b:%d", i), "b", time.Hour)
+	}
+	for i := 0; i < 50; i++ {
+		c.Set(fmt.Sprintf("tenant:a:%d", i), "a", time.Hour)
+	}
+
+	for i := 0; i < 20; i++ {
+		if k := fmt.Sprintf("tenant:b:%d", i); !c.Has(k) {
+			t.Fatalf("%s was evicted by inserts under another tenant's quota", k)
+		}
+	}
+	if keys, _ := c.MatchKeys("tenant:a:*"); len(keys) != 10 {
+		t.Fatalf("tenant a holds %d keys, want its quota of 10", len(keys))
+	}
+	// The oldest keys under the prefix went first.
+	for i := 40; i < 50; i++ {
+		if k := fmt.Sprintf("tenant:a:%d", i); !c.Has(k) {
+			t.Fatalf("%s, one of the newest keys, was evicted", k)
+		}
+	}
+
+	// Updating a key and deleting one keep the count in step.
+	c.Set("tenant:a:45", "updated", time.Hour)
+	c.Delete("tenant:a:40")
+	c.Set("tenant:a:new", "a", time.Hour)
+	if !c.Has("tenant:a:41") || c.Len() != 30 {
+		t.Fatalf("Len = %d; an insert into a prefix below its quota evicted", c.Len())
+	}
+}
+
+func TestPrefixQuotaLongestPrefix(t *testing.T) {
+	c := New(WithPrefixQuota("t:", 5), WithPrefixQuota("t:vip:", 2))
+	for i := 0; i < 4; i++ {
+		c.Set(fmt.Sprintf("t:vip:%d", i), "v", time.Hour)
+		c.Set(fmt.Sprintf("t:%d", i), "v", time.Hour)
+	}
+	if n := c.Len(); n != 6 {
+		t.Fatalf("Len = %d, want 4 under t: and 2 under t:vip:", n)
+	}
+
+	c.Flush()
+	c.Set("t:vip:x", "v", time.Hour)
+	c.Set("t:vip:y", "v", time.Hour)
+	if n := c.Len(); n != 2 {
+		t.Fatalf("Len = %d after Flush, want 2; Flush did not reset the quota", n)
+	}
+}
+
+func TestPrefixQuotaAdmissionFirst(t *testing.T) {
+	c := New(WithMaxItems(2), WithAdmissionPolicy(TinyLFU), WithPrefixQuota("p:", 1))
+	c.Set("p:a", "1", time.Hour)
+	c.Set("x", "2", time.Hour)
+	for i := 0; i < 5; i++ {
+		c.Get("p:a")
+		c.Get("x")
+	}
+
+	if err := c.TrySet("p:b", "3", time.Hour); !errors.Is(err, ErrCapacity) {
+		t.Fatalf("TrySet of a cold key: err = %v, want ErrCapacity", err)
+	}
+	if !c.Has("p:a") {
+		t.Fatal("a rejected write evicted a key under its quota")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WithWriteRateLimit limits the writes that store a value, from Set and
+// TrySet to Increment and Append, to perSecond per second, allowing bursts
+// of up to perSecond. A SetMulti call counts as one write. Writes over the
+// limit are dropped rather than queued, and those that return an error
+// report ErrRateLimited. Deletes and expiry changes are not limited. Zero or
+// negative means no limit.
+func WithWriteRateLimit(perSecond int) Option {
+	return func(c *Cache) {
+		if perSecond <= 0 {
+			c.writeLimit = nil
+			return
+		}
+		interval := time.Second / time.Duration(perSecond)
+		c.writeLimit = &rateLimiter{
+			interval:  int64(interval),
+			tolerance: int64(time.Second - interval),
+		}
+	}
+}
+
+// rateLimited reports whether a write is over the write rate limit, taking
+// a token if it is not.
+func (c *Cache) rateLimited() bool {
+	return c.writeLimit != nil && !c.writeLimit.allow(c.clock())
+}
+
+// rateLimiter is a token bucket implemented as the generic cell rate
+// algorithm: instead of counting tokens it tracks the time at which the
+// bucket would be full again, which fits in a single word updated with
+// compare-and-swap, so allow never takes a lock.
+type rateLimiter struct {
+	tat       int64 // theoretical arrival time of the next write, UnixNano
+	interval  int64 // time one token takes to refill
+	tolerance int64 // how far tat may run ahead of now, i.e. the burst size
+}
+
+// allow reports whether a write at now is within the limit, taking a token
+// if it is.
+func (l *rateLimiter) allow(now time.Time) bool {
+	n := now.UnixNano()
+	for {
+		tat := atomic.LoadInt64(&l.tat)
+		next := tat
+		if next < n {
+			next = n
+		}
+		if next-n > l.tolerance {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&l.tat, tat, next+l.interval) {
+			return true
+		}
+	}
+}
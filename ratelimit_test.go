@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWriteRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithWriteRateLimit(10))
+
+	burst := func(n int) (rejected int) {
+		for i := 0; i < n; i++ {
+			err := c.TrySet(strconv.Itoa(i), "v", 0)
+			if errors.Is(err, ErrRateLimited) {
+				rejected++
+			} else if err != nil {
+				t.Fatalf("TrySet: %v", err)
+			}
+		}
+		return rejected
+	}
+
+	if n := burst(25); n != 15 {
+		t.Fatalf("rejected %d of a burst of 25, want 15", n)
+	}
+	clock.Advance(500 * time.Millisecond)
+	if n := burst(10); n != 5 {
+		t.Fatalf("rejected %d of 10 after half a second, want 5", n)
+	}
+
+	// The bucket is empty again, so Set drops the write.
+	c.Set("dropped", "v", 0)
+	if c.Has("dropped") {
+		t.Fatal("Set stored a write over the limit")
+	}
+}
+
+func TestWriteRateLimitConcurrent(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithWriteRateLimit(100))
+	results := make(chan error, 400)
+	for g := 0; g < 4; g++ {
+		go func(g int) {
+			for i := 0; i < 100; i++ {
+				results <- c.TrySet(strconv.Itoa(g*100+i), "v", 0)
+			}
+		}(g)
+	}
+	accepted := 0
+	for i := 0; i < 400; i++ {
+		if <-results == nil {
+			accepted++
+		}
+	}
+	if accepted != 100 {
+		t.Fatalf("accepted %d concurrent writes, want 100", accepted)
+	}
+}
+
+func TestWriteRateLimitAllWrites(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithWriteRateLimit(1))
+	c.Set("n", "1", 0)
+
+	// The burst of one is spent, so every write below is over the limit.
+	if c.Add("a", "v", 0) {
+		t.Fatal("Add stored a write over the limit")
+	}
+	if _, ok := c.GetSet("n", "2", 0); ok {
+		t.Fatal("GetSet stored a write over the limit")
+	}
+	if c.CompareAndSwap("n", "1", "2", 0) {
+		t.Fatal("CompareAndSwap stored a write over the limit")
+	}
+	if _, err := c.Increment("n", 1); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Increment err = %v, want ErrRateLimited", err)
+	}
+	if _, err := c.IncrementFloat("n", 1); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("IncrementFloat err = %v, want ErrRateLimited", err)
+	}
+	if _, err := c.Append("n", "0", 0); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Append err = %v, want ErrRateLimited", err)
+	}
+	if _, ok := c.SetIfGreater("n", 5, 0); ok {
+		t.Fatal("SetIfGreater stored a write over the limit")
+	}
+	c.SetMulti(map[string]string{"b": "v"}, 0)
+	c.SetWithTags("c", "v", 0, "t")
+	c.SetWithCost("d", "v", 0, 1)
+	c.SetVersioned("e", "v", 0, 0)
+	for _, k := range []string{"b", "c", "d", "e"} {
+		if c.Has(k) {
+			t.Fatalf("write of %q over the limit was stored", k)
+		}
+	}
+	if v, _ := c.Get("n"); v != "1" {
+		t.Fatalf("n = %q after writes over the limit, want 1", v)
+	}
+
+	clock.Advance(time.Second)
+	if _, err := c.Increment("n", 1); err != nil {
+		t.Fatalf("Increment after the bucket refilled: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+// WithBeforeReap makes the janitor call f with the key and value of every
+// expired item it is about to remove, before removing it. f runs without
+// the lock held, so it may use the cache. Unlike OnEvicted, it is only
+// called for expired items the janitor reaps, not for deleted, evicted or
+// idle ones, nor for expired items removed when they are read.
+func WithBeforeReap(f func(key, value string)) Option {
+	return func(c *Cache) {
+		c.beforeReap = f
+	}
+}
+
+// announceReap passes the items expired at now to the before-reap hook. The
+// sweep then only removes items expired at now, so each one the hook saw is
+// reaped at most once and none is reaped without the hook seeing it. Each
+// item is checked again just before its call, so one rewritten while
+// earlier calls ran is skipped.
+func (c *Cache) announceReap(now int64) {
+	type entry struct {
+		it      *item
+		version uint64
+	}
+
+	c.mu.RLock()
+	var due []entry
+	for _, it := range c.expiries.expiredAt(now) {
+		due = append(due, entry{it, it.version})
+	}
+	c.mu.RUnlock()
+
+	for _, e := range due {
+		c.mu.Lock()
+		k, val, compressed := e.it.key, e.it.val, e.it.compressed
+		still := c.items[k] == e.it && e.it.version == e.version && e.it.expired(now)
+		c.unlock()
+		if !still {
+			continue
+		}
+		if v, err := c.decode(val, compressed); err == nil {
+			c.beforeReap(k, v)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBeforeReap(t *testing.T) {
+	clock := newFakeClock()
+	var c *Cache
+	seen := map[string]string{}
+	c = New(WithClock(clock.Now), WithBeforeReap(func(key, value string) {
+		if _, dup := seen[key]; dup {
+			t.Errorf("hook called twice for %s", key)
+		}
+		seen[key] = value
+		c.mu.RLock()
+		_, stored := c.items[key]
+		c.mu.RUnlock()
+		if !stored {
+			t.Errorf("hook called for %s after it was removed", key)
+		}
+	}))
+	c.Set("a", "1", time.Second)
+	c.Set("b", "2", 2*time.Second)
+	c.Set("long", "3", time.Hour)
+	c.Set("forever", "4", NoExpiration)
+
+	clock.Advance(3 * time.Second)
+	c.sweep()
+	c.sweep()
+	if len(seen) != 2 || seen["a"] != "1" || seen["b"] != "2" {
+		t.Fatalf("hook saw %v, want map[a:1 b:2]", seen)
+	}
+	if n := c.Size(); n != 2 {
+		t.Fatalf("%d items left after the sweep, want 2", n)
+	}
+
+	c.Set("deleted", "5", time.Second)
+	c.Delete("deleted")
+	clock.Advance(time.Hour)
+	c.sweep()
+	if _, ok := seen["deleted"]; ok || seen["long"] != "3" || len(seen) != 3 {
+		t.Fatalf("hook saw %v, want long added and deleted left out", seen)
+	}
+}
+
+func TestBeforeReapSkipsRewritten(t *testing.T) {
+	clock := newFakeClock()
+	var c *Cache
+	var seen []string
+	c = New(WithClock(clock.Now), WithBeforeReap(func(key, value string) {
+		seen = append(seen, key)
+		// Whichever key comes first, rewrite the other before its turn.
+		other := "b"
+		if key == "b" {
+			other = "a"
+		}
+		c.Set(other, "new", time.Hour)
+	}))
+	c.Set("a", "1", time.Second)
+	c.Set("b", "2", time.Second)
+
+	clock.Advance(2 * time.Second)
+	c.sweep()
+	if len(seen) != 1 {
+		t.Fatalf("hook saw %v, want only the key that was not rewritten", seen)
+	}
+	other := map[string]string{"a": "b", "b": "a"}[seen[0]]
+	if v, ok := c.Get(other); !ok || v != "new" {
+		t.Fatalf("Get(%s) = %q, %v after the sweep; want the rewritten value", other, v, ok)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "sync/atomic"
+
+// reapBatch is the most expired items the reaper removes under one lock, so
+// readers are never held up for long.
+const reapBatch = 256
+
+// WithAsyncReap makes reads that find an expired item leave its removal to a
+// background reaper instead of taking the write lock themselves, so reads
+// stay fast. The item is still reported as missing straight away. Woken by
+// such a read, the reaper removes every expired item, a batch at a time. It
+// stops when Close is called.
+func WithAsyncReap() Option {
+	return func(c *Cache) {
+		c.reapSignal = make(chan struct{}, 1)
+	}
+}
+
+// queueReap wakes the reaper unless it has already been woken. Once it has,
+// this costs a read a single atomic load.
+func (c *Cache) queueReap() {
+	if atomic.LoadInt32(&c.reapPending) == 0 && atomic.CompareAndSwapInt32(&c.reapPending, 0, 1) {
+		select {
+		case c.reapSignal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *Cache) reaper() {
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-c.reapSignal:
+		}
+		// Reset before reaping, so an item that expires meanwhile wakes
+		// the reaper again.
+		atomic.StoreInt32(&c.reapPending, 0)
+		for more := true; more; {
+			c.mu.Lock()
+			more = c.reapLocked(reapBatch)
+			c.unlock()
+		}
+	}
+}
+
+// reapLocked removes up to n expired items and reports whether more remain.
+// The caller must hold the write lock.
+func (c *Cache) reapLocked(n int) bool {
+	now := c.clock().UnixNano()
+	for ; n > 0; n-- {
+		it := c.expiries.peek()
+		if it == nil || !it.expired(now) {
+			return false
+		}
+		c.removeLocked(it.key, ReasonExpired)
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncReap(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now), WithAsyncReap())
+	defer c.Close()
+	for i := 0; i < 100; i++ {
+		c.Set(strconv.Itoa(i), "v", time.Second)
+	}
+	c.Set("live", "v", time.Hour)
+	clock.Advance(2 * time.Second)
+
+	for i := 0; i < 100; i++ {
+		if _, ok := c.Get(strconv.Itoa(i)); ok {
+			t.Fatalf("Get(%d) found an expired item", i)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for c.Size() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d items left after the reaper had a second, want 1", c.Size())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !c.Has("live") {
+		t.Fatal("the reaper removed a live item")
+	}
+}
+
+func BenchmarkGetExpiredInline(b *testing.B) {
+	benchmarkGetExpired(b)
+}
+
+func BenchmarkGetExpiredAsyncReap(b *testing.B) {
+	benchmarkGetExpired(b, WithAsyncReap())
+}
+
+// benchmarkGetExpired reads a different expired item every iteration, so
+// each read finds one still to be removed, from parallel readers.
+func benchmarkGetExpired(b *testing.B, opts ...Option) {
+	clock := newFakeClock()
+	c := New(append(opts, WithClock(clock.Now))...)
+	defer c.Close()
+	keys := make([]string, b.N)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		c.Set(keys[i], "v", time.Second)
+	}
+	clock.Advance(2 * time.Second)
+
+	var next int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Get(keys[atomic.AddInt64(&next, 1)-1])
+		}
+	})
+}
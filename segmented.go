@@ -0,0 +1,75 @@
+package main
+
+// WithSegmentedLRU selects EvictSegmentedLRU: the cache is split into a cold
+// segment that new items enter and a hot segment holding up to hotFraction
+// of the capacity. An item is promoted to the hot segment when it is used a
+// second time, and the least recently used hot item is demoted back to cold
+// when the hot segment is full. Items are only evicted from the cold end, so
+// keys used more than once survive a flood of keys used only once.
+//
+// Both segments share the usual list, the hot one at the front, so a
+// demoted item simply becomes the most recent cold one. Without an item
+// limit the hot segment may hold hotFraction of the current items.
+func WithSegmentedLRU(hotFraction float64) Option {
+	return func(c *Cache) {
+		c.policy = EvictSegmentedLRU
+		c.hotFraction = hotFraction
+	}
+}
+
+// insertColdLocked adds a new item at the front of the cold segment. The
+// caller must hold the write lock.
+func (c *Cache) insertColdLocked(it *item) {
+	if c.hotTail == nil {
+		c.order.pushFront(it)
+		return
+	}
+	c.order.insertAfter(c.hotTail, it)
+}
+
+// promoteLocked records a use of it under EvictSegmentedLRU, moving it to
+// the front of the hot segment. The caller must hold the write lock.
+func (c *Cache) promoteLocked(it *item) {
+	if it.hot {
+		if it == c.hotTail && it.prev != nil {
+			c.hotTail = it.prev
+		}
+		c.order.moveToFront(it)
+		return
+	}
+
+	c.order.moveToFront(it)
+	it.hot = true
+	c.hotItems++
+	if c.hotTail == nil {
+		c.hotTail = it
+	}
+
+	limit := c.maxItems
+	if limit <= 0 {
+		limit = len(c.items)
+	}
+	if float64(c.hotItems) > c.hotFraction*float64(limit) {
+		// The last hot item becomes the first cold one without moving.
+		demoted := c.hotTail
+		demoted.hot = false
+		c.hotItems--
+		c.hotTail = demoted.prev
+		if c.hotItems == 0 {
+			c.hotTail = nil
+		}
+	}
+}
+
+// unlinkLocked removes it from the list, keeping the segments in order. The
+// caller must hold the write lock.
+func (c *Cache) unlinkLocked(it *item) {
+	if it.hot {
+		it.hot = false
+		c.hotItems--
+		if it == c.hotTail {
+			c.hotTail = it.prev
+		}
+	}
+	c.order.remove(it)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// checkSegments verifies that the hot items form a prefix of the list that
+// ends at hotTail and holds hotItems items.
+func checkSegments(t *testing.T, c *Cache) {
+	t.Helper()
+	hot, cold := 0, false
+	var last *item
+	for it := c.order.head; it != nil; it = it.next {
+		switch {
+		case !it.hot:
+			cold = true
+		case cold:
+			t.Fatalf("hot item %s found after the hot segment", it.key)
+		default:
+			hot++
+			last = it
+		}
+	}
+	if hot != c.hotItems || last != c.hotTail {
+		t.Fatalf("hot segment has %d items ending at %p, cache records %d ending at %p", hot, last, c.hotItems, c.hotTail)
+	}
+}
+
+func TestSegmentedLRUProtectsRepeatedKeys(t *testing.T) {
+	flood := func(c *Cache) {
+		c.Set("twice", "v", time.Hour)
+		c.Get("twice")
+		for i := 0; i < 100; i++ {
+			c.Set(strconv.Itoa(i), "v", time.Hour)
+		}
+	}
+
+	lru := New(WithMaxItems(10), WithEvictionPolicy(EvictLRU))
+	flood(lru)
+	if lru.Has("twice") {
+		t.Fatal("twice survived the flood under plain LRU; the test workload is too weak")
+	}
+
+	c := New(WithMaxItems(10), WithSegmentedLRU(0.5))
+	flood(c)
+	checkSegments(t, c)
+	if !c.Has("twice") {
+		t.Fatal("a key used twice was evicted by keys used once")
+	}
+	if n := c.Size(); n != 10 {
+		t.Fatalf("Size = %d, want 10", n)
+	}
+}
+
+func TestSegmentedLRUDemotes(t *testing.T) {
+	c := New(WithMaxItems(4), WithSegmentedLRU(0.5))
+	for _, k := range []string{"a", "b", "c", "d"} {
+		c.Set(k, k, time.Hour)
+	}
+	// a and b fill the hot segment; promoting c demotes a.
+	for _, k := range []string{"a", "b", "c"} {
+		c.Get(k)
+		checkSegments(t, c)
+	}
+	if c.items["a"].hot || !c.items["b"].hot || !c.items["c"].hot {
+		t.Fatal("the least recently used hot item was not demoted")
+	}
+
+	// d is the coldest, then a.
+	c.Set("e", "e", time.Hour)
+	c.Set("f", "f", time.Hour)
+	checkSegments(t, c)
+	if c.Has("d") || c.Has("a") {
+		t.Fatal("cold items survived while the hot ones were kept")
+	}
+
+	c.Delete("c")
+	checkSegments(t, c)
+	c.Flush()
+	checkSegments(t, c)
+}
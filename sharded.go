@@ -0,0 +1,90 @@
+package main
+
+import "time"
+
+// ShardedCache spreads keys over independent caches, each with its own lock,
+// so writers to different shards do not contend.
+type ShardedCache struct {
+	shards []*Cache
+	hash   func(string) uint64
+}
+
+// ShardedOption configures a ShardedCache at construction time.
+type ShardedOption func(*ShardedCache)
+
+// WithHasher makes the sharded cache pick a key's shard from h(key) modulo
+// the number of shards instead of the built-in FNV-1a hash. h must be
+// deterministic, so a key always maps to the same shard.
+func WithHasher(h func(string) uint64) ShardedOption {
+	return func(sc *ShardedCache) {
+		sc.hash = h
+	}
+}
+
+// NewShardedCache creates a cache of the given number of shards. maxItems is
+// the total limit and is split evenly between the shards.
+func NewShardedCache(shards int, ed time.Duration, maxItems int, opts ...ShardedOption) *ShardedCache {
+	if shards < 1 {
+		shards = 1
+	}
+	perShard := 0
+	if maxItems > 0 {
+		perShard = (maxItems + shards - 1) / shards
+	}
+
+	sc := &ShardedCache{shards: make([]*Cache, shards), hash: fnv64a}
+	for _, opt := range opts {
+		opt(sc)
+	}
+	for i := range sc.shards {
+		sc.shards[i] = NewCacheWithJanitor(ed, perShard)
+	}
+	return sc
+}
+
+func (sc *ShardedCache) Set(k, v string, expiry time.Duration) {
+	sc.shard(k).Set(k, v, expiry)
+}
+
+func (sc *ShardedCache) Get(k string) (string, bool) {
+	return sc.shard(k).Get(k)
+}
+
+func (sc *ShardedCache) Delete(k string) {
+	sc.shard(k).Delete(k)
+}
+
+// Len returns the number of items that have not expired yet.
+func (sc *ShardedCache) Len() int {
+	n := 0
+	for _, s := range sc.shards {
+		n += s.Len()
+	}
+	return n
+}
+
+// Close stops the janitors of all shards.
+func (sc *ShardedCache) Close() {
+	for _, s := range sc.shards {
+		s.Close()
+	}
+}
+
+func (sc *ShardedCache) shard(k string) *Cache {
+	return sc.shards[sc.hash(k)%uint64(len(sc.shards))]
+}
+
+// fnv64a is the 64-bit FNV-1a hash, inlined to avoid allocating a hash.Hash
+// on every call.
+func fnv64a(s string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime64
+	}
+	return h
+}
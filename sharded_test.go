@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedCache(t *testing.T) {
+	sc := NewShardedCache(8, time.Minute, 1000)
+	defer sc.Close()
+
+	for i := 0; i < 100; i++ {
+		k := fmt.Sprintf("key%d", i)
+		sc.Set(k, k, time.Hour)
+	}
+	for i := 0; i < 100; i++ {
+		k := fmt.Sprintf("key%d", i)
+		if v, ok := sc.Get(k); !ok || v != k {
+			t.Fatalf("Get(%s) = %q, %v; want %q, true", k, v, ok, k)
+		}
+		if !sc.shard(k).Has(k) {
+			t.Fatalf("key %s is not in the shard it hashes to", k)
+		}
+	}
+	if n := sc.Len(); n != 100 {
+		t.Fatalf("Len = %d, want 100", n)
+	}
+
+	sc.Delete("key0")
+	if _, ok := sc.Get("key0"); ok {
+		t.Fatal("Get found a deleted key")
+	}
+}
+
+func TestShardAssignmentIsStable(t *testing.T) {
+	sc := NewShardedCache(16, time.Minute, 0)
+	defer sc.Close()
+
+	for i := 0; i < 1000; i++ {
+		k := strconv.Itoa(i)
+		if sc.shard(k) != sc.shard(k) {
+			t.Fatalf("key %s mapped to different shards", k)
+		}
+
+		h := fnv.New64a()
+		h.Write([]byte(k))
+		if got, want := fnv64a(k), h.Sum64(); got != want {
+			t.Fatalf("fnv64a(%s) = %d, want %d", k, got, want)
+		}
+	}
+}
+
+func TestWithHasher(t *testing.T) {
+	hash := func(k string) uint64 { return uint64(len(k)) }
+	sc := NewShardedCache(4, time.Minute, 0, WithHasher(hash))
+	defer sc.Close()
+
+	for _, k := range []string{"a", "bb", "ccc", "dddd", "eeeee", "ffffff"} {
+		sc.Set(k, k, time.Hour)
+		want := sc.shards[hash(k)%4]
+		if sc.shard(k) != want || !want.Has(k) {
+			t.Fatalf("key %s is not in shard %d", k, hash(k)%4)
+		}
+	}
+	// "a" and "eeeee" both hash to shard 1.
+	if n := sc.shards[1].Len(); n != 2 {
+		t.Fatalf("shard 1 holds %d items, want 2", n)
+	}
+}
+
+func BenchmarkCacheSetParallel(b *testing.B) {
+	c := NewCacheWithJanitor(time.Minute, 0)
+	defer c.Close()
+	benchmarkSetParallel(b, c.Set)
+}
+
+func BenchmarkShardedCacheSetParallel(b *testing.B) {
+	sc := NewShardedCache(32, time.Minute, 0)
+	defer sc.Close()
+	benchmarkSetParallel(b, sc.Set)
+}
+
+func benchmarkSetParallel(b *testing.B, set func(k, v string, expiry time.Duration)) {
+	var n int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			k := strconv.FormatInt(atomic.AddInt64(&n, 1)%20000, 10)
+			set(k, k, time.Hour)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot returns a read-only copy of the cache as it is now, for
+// consistent point-in-time backups. The copy is detached: later writes to c
+// do not change it. It keeps c's expiry, eviction and compression settings
+// but has no janitor, callbacks or store.
+//
+// Values are shared rather than copied, so taking a snapshot only holds the
+// read lock for as long as it takes to copy the item metadata.
+func (c *Cache) Snapshot() *Cache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	s := c.detached()
+	s.readOnly = 1
+	s.hotItems = c.hotItems
+	s.items = make(map[string]*item, len(c.items))
+	// Walk from the back so the copies end up in the same order.
+	for it := c.order.back(); it != nil; it = it.prev {
+		cp := &item{
+			hits:       atomic.LoadUint64(&it.hits),
+			lastAccess: atomic.LoadInt64(&it.lastAccess),
+			key:        it.key,
+			val:        it.val,
+			compressed: it.compressed,
+			expiry:     it.expiry,
+			ttl:        it.ttl,
+			createdAt:  it.createdAt,
+			cost:       it.cost,
+			version:    it.version,
+			hot:        it.hot,
+			heapIndex:  -1,
+		}
+		if it == c.hotTail {
+			s.hotTail = cp
+		}
+		s.items[cp.key] = cp
+		if b := s.bloomFilter(); b != nil {
+			b.add(cp.key)
+		}
+		s.addToQuotaLocked(cp, s.quotaFor(cp.key))
+		s.order.pushFront(cp)
+		s.expiries.update(cp)
+		s.setTagsLocked(cp, it.tags)
+	}
+	s.size = int64(len(s.items))
+	s.peakItems = len(s.items)
+	s.bytes = c.bytes
+	s.cost = c.cost
+	s.version = c.version
+	return s
+}
+
+// detached returns an empty cache with c's expiry, eviction and encoding
+// settings and nothing else: no janitor, callbacks, store or admission
+// policy. The caller must hold c's lock.
+func (c *Cache) detached() *Cache {
+	s := &Cache{
+		mu:            &sync.RWMutex{},
+		items:         make(map[string]*item),
+		stop:          make(chan struct{}),
+		loads:         make(map[string]*call),
+		defaultExpiry: c.defaultExpiry,
+		maxItems:      c.maxItems,
+		maxBytes:      c.maxBytes,
+		maxCost:       c.maxCost,
+		maxValueSize:  c.maxValueSize,
+		minTTL:        c.minTTL,
+		policy:        c.policy,
+		hotFraction:   c.hotFraction,
+		sliding:       c.sliding,
+		idleTimeout:   c.idleTimeout,
+		compressMin:   c.compressMin,
+		aead:          c.aead,
+		clock:         c.clock,
+		quotas:        c.copyQuotas(),
+	}
+	if b := c.bloomFilter(); b != nil {
+		s.bloom.Store(newBloomFilter(b.expected))
+	}
+	if c.jitter > 0 {
+		s.jitter = c.jitter
+		s.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return s
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", NoExpiration)
+	c.SetWithTags("c", "3", 0, "t")
+
+	s := c.Snapshot()
+	c.Set("a", "changed", time.Hour)
+	c.Delete("b")
+	c.Set("d", "4", 0)
+	c.InvalidateTag("t")
+
+	want := map[string]string{"a": "1", "b": "2", "c": "3"}
+	if got := s.Items(); len(got) != len(want) {
+		t.Fatalf("snapshot Items = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got, ok := s.Get(k); !ok || got != v {
+			t.Errorf("snapshot Get(%s) = %q, %v; want %q, true", k, got, ok, v)
+		}
+	}
+	if ttl, ok := s.TTL("b"); !ok || ttl != NoExpiration {
+		t.Errorf("snapshot TTL(b) = %v, %v; want NoExpiration, true", ttl, ok)
+	}
+
+	s.Set("a", "written", 0)
+	if v, _ := s.Get("a"); v != "1" {
+		t.Fatalf("snapshot accepted a write: Get(a) = %q", v)
+	}
+	if v, _ := c.Get("a"); v != "changed" {
+		t.Fatalf("original Get(a) = %q, want \"changed\"", v)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the cache's counters.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64 // items removed by expiry or capacity eviction
+	Items     int
+
+	// Over live items that expire, how long they have left to live on
+	// average and at most; zero if there are none.
+	AverageTTL time.Duration
+	MaxTTL     time.Duration
+
+	// When the least and most recently written live items were written;
+	// the zero time if there are none.
+	OldestCreated time.Time
+	NewestCreated time.Time
+}
+
+// Stats returns the current counters and item count, with the TTL and age
+// figures computed in a single pass over the items under the read lock.
+func (c *Cache) Stats() Stats {
+	s := Stats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Items:     c.Size(),
+	}
+
+	c.mu.RLock()
+	now := c.clock().UnixNano()
+	var max time.Duration
+	var total float64 // nanoseconds; a Duration overflows on large caches
+	var expiring int
+	var oldest, newest int64
+	for _, it := range c.items {
+		if it.expired(now) {
+			continue
+		}
+		if it.expiry != 0 {
+			ttl := time.Duration(it.expiry - now)
+			total += float64(ttl)
+			expiring++
+			if ttl > max {
+				max = ttl
+			}
+		}
+		if oldest == 0 || it.createdAt < oldest {
+			oldest = it.createdAt
+		}
+		if it.createdAt > newest {
+			newest = it.createdAt
+		}
+	}
+	c.mu.RUnlock()
+
+	if expiring > 0 {
+		s.AverageTTL = time.Duration(total / float64(expiring))
+		s.MaxTTL = max
+	}
+	if oldest != 0 {
+		s.OldestCreated, s.NewestCreated = time.Unix(0, oldest), time.Unix(0, newest)
+	}
+	return s
+}
+
+// ResetStats sets the hit, miss and eviction counters to zero.
+func (c *Cache) ResetStats() {
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+	atomic.StoreUint64(&c.evictions, 0)
+}
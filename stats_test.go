@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	c := NewCacheWithJanitor(time.Minute, 2, WithClock(clock.Now))
+	defer c.Close()
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", time.Hour)
+
+	c.Get("a")
+	c.Get("b")
+	c.GetOrDelete("a")
+	c.Get("missing")
+
+	c.Set("c", "3", time.Hour) // evicts one item
+	c.Set("short", "4", time.Millisecond)
+	clock.Advance(5 * time.Millisecond)
+	c.Get("short") // expired: a miss and an eviction
+
+	left := time.Hour - 5*time.Millisecond
+	want := Stats{Hits: 3, Misses: 2, Evictions: 3, Items: 1,
+		AverageTTL: left, MaxTTL: left, OldestCreated: start, NewestCreated: start}
+	if got := c.Stats(); got != want {
+		t.Fatalf("Stats = %+v, want %+v", got, want)
+	}
+
+	c.ResetStats()
+	want.Hits, want.Misses, want.Evictions = 0, 0, 0
+	if got := c.Stats(); got != want {
+		t.Fatalf("Stats after ResetStats = %+v, want only the counters reset", got)
+	}
+}
+
+func TestStatsTTL(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	if s := c.Stats(); s.AverageTTL != 0 || s.MaxTTL != 0 || !s.OldestCreated.IsZero() {
+		t.Fatalf("Stats of an empty cache = %+v, want zero TTLs and times", s)
+	}
+
+	first := clock.Now()
+	c.Set("forever", "v", NoExpiration)
+	clock.Advance(time.Minute)
+	c.Set("m10", "v", 10*time.Minute)
+	c.Set("m20", "v", 20*time.Minute)
+	c.Set("m30", "v", 30*time.Minute)
+	c.Set("gone", "v", time.Second)
+	clock.Advance(2 * time.Second)
+	last := clock.Now()
+	c.Set("h1", "v", time.Hour)
+
+	s := c.Stats()
+	// (10+20+30+60)/4 minutes, less the 2s that passed for the first three.
+	if want := 30*time.Minute - 6*time.Second/4; s.AverageTTL != want {
+		t.Errorf("AverageTTL = %v, want %v", s.AverageTTL, want)
+	}
+	if s.MaxTTL != time.Hour {
+		t.Errorf("MaxTTL = %v, want 1h", s.MaxTTL)
+	}
+	if !s.OldestCreated.Equal(first) || !s.NewestCreated.Equal(last) {
+		t.Errorf("created between %v and %v, want %v and %v", s.OldestCreated, s.NewestCreated, first, last)
+	}
+}
+
+func TestStatsAverageTTLLargeCache(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	const ttl = 30 * 24 * time.Hour
+	// Together the TTLs are far beyond what a Duration can hold.
+	for i := 0; i < 10000; i++ {
+		c.Set(strconv.Itoa(i), "v", ttl)
+	}
+	if s := c.Stats(); s.AverageTTL != ttl {
+		t.Fatalf("AverageTTL = %v, want %v", s.AverageTTL, ttl)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import "time"
+
+// Store is an external backing store the cache writes through to.
+type Store interface {
+	// Load returns the value stored under key, with ok false if there is
+	// none.
+	Load(key string) (value string, ok bool, err error)
+	// Save stores value under key. ttl is how long the cache keeps it, 0
+	// meaning forever.
+	Save(key, value string, ttl time.Duration) error
+}
+
+// WithWriteThrough makes every write that stores a value also save it to s,
+// and GetOrLoad try s before its loader on a miss. s is called without the
+// cache lock held. TrySet and SetContext report a Save error after the value
+// has been cached; other writes report it to the logger as "save_failed".
+// Bulk loads are not written through: NewCacheFromMap, ReplaceAll, Merge and
+// restoring a persistence file.
+func WithWriteThrough(s Store) Option {
+	return func(c *Cache) {
+		c.store = s
+	}
+}
+
+// pendingSave is a write made under the lock, to be saved to the store once
+// the lock is released.
+type pendingSave struct {
+	key, value string
+	ttl        time.Duration
+}
+
+// saveLocked queues v, just stored under k, for the store. Nothing is queued
+// if k's item was evicted again to make room. The caller must hold the write
+// lock.
+func (c *Cache) saveLocked(k, v string) {
+	if c.store == nil {
+		return
+	}
+	it, ok := c.items[k]
+	if !ok {
+		return
+	}
+	var ttl time.Duration
+	if it.expiry != 0 {
+		ttl = time.Duration(it.expiry - c.clock().UnixNano())
+	}
+	c.saves = append(c.saves, pendingSave{k, v, ttl})
+}
+
+// flushSaves saves writes queued by saveLocked. It must be called without
+// the lock held.
+func (c *Cache) flushSaves(saves []pendingSave) {
+	for _, s := range saves {
+		if err := c.store.Save(s.key, s.value, s.ttl); err != nil && c.logger != nil {
+			c.logger("save_failed", map[string]any{"key": s.key, "error": err})
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is an in-memory Store that records the calls made to it.
+type memStore struct {
+	mu    sync.Mutex
+	data  map[string]string
+	ttls  map[string]time.Duration
+	saves int
+	loads int
+}
+
+func newMemStore() *memStore {
+	return &memStore{data: make(map[string]string), ttls: make(map[string]time.Duration)}
+}
+
+func (s *memStore) Load(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads++
+	v, ok := s.data[key]
+	return v, ok, nil
+}
+
+func (s *memStore) Save(key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saves++
+	s.data[key], s.ttls[key] = value, ttl
+	return nil
+}
+
+func TestWriteThroughSave(t *testing.T) {
+	s := newMemStore()
+	c := New(WithDefaultExpiry(time.Minute), WithWriteThrough(s))
+	c.Set("a", "1", time.Hour)
+	c.Set("b", "2", DefaultExpiration)
+	c.Set("c", "3", NoExpiration)
+
+	want := map[string]time.Duration{"a": time.Hour, "b": time.Minute, "c": 0}
+	for k, ttl := range want {
+		if s.ttls[k] != ttl {
+			t.Errorf("saved %s with ttl %v, want %v", k, s.ttls[k], ttl)
+		}
+	}
+	if s.data["a"] != "1" || s.saves != 3 {
+		t.Fatalf("store = %v after %d saves, want a=1 after 3", s.data, s.saves)
+	}
+}
+
+func TestWriteThroughLoad(t *testing.T) {
+	s := newMemStore()
+	s.data["stored"] = "from store"
+	c := New(WithWriteThrough(s))
+	loaderCalls := 0
+	loader := func() (string, error) {
+		loaderCalls++
+		return "from loader", nil
+	}
+
+	if v, err := c.GetOrLoad("stored", time.Hour, loader); err != nil || v != "from store" {
+		t.Fatalf("GetOrLoad(stored) = %q, %v; want \"from store\", nil", v, err)
+	}
+	if loaderCalls != 0 || s.saves != 0 {
+		t.Fatalf("loader calls = %d, saves = %d after a store hit; want 0, 0", loaderCalls, s.saves)
+	}
+
+	// Cached now, so the store is not asked again.
+	c.GetOrLoad("stored", time.Hour, loader)
+	if s.loads != 1 {
+		t.Fatalf("store loads = %d, want 1", s.loads)
+	}
+
+	if v, err := c.GetOrLoad("new", time.Hour, loader); err != nil || v != "from loader" {
+		t.Fatalf("GetOrLoad(new) = %q, %v; want \"from loader\", nil", v, err)
+	}
+	if loaderCalls != 1 || s.data["new"] != "from loader" {
+		t.Fatalf("loader calls = %d, store = %v; want 1 and new saved", loaderCalls, s.data)
+	}
+}
+
+func TestWriteThroughOtherWrites(t *testing.T) {
+	clock := newFakeClock()
+	s := newMemStore()
+	c := New(WithClock(clock.Now), WithWriteThrough(s))
+
+	c.Add("add", "1", time.Hour)
+	c.SetMulti(map[string]string{"multi": "2"}, 0)
+	c.Set("n", "10", time.Minute)
+	c.Increment("n", 5)
+	c.Append("log", "ab", 0)
+	c.Append("log", "c", 0)
+	c.CompareAndSwap("add", "1", "one", time.Hour)
+	c.SetWithTags("tagged", "t", 0, "x")
+	c.SetBytes("bytes", []byte("b"), 0)
+
+	want := map[string]string{"add": "one", "multi": "2", "n": "15", "log": "abc", "tagged": "t", "bytes": "b"}
+	for k, v := range want {
+		if s.data[k] != v {
+			t.Errorf("store[%s] = %q, want %q", k, s.data[k], v)
+		}
+	}
+	if s.ttls["n"] != time.Minute {
+		t.Errorf("Increment saved n with ttl %v, want the %v it had left", s.ttls["n"], time.Minute)
+	}
+
+	if c.Add("add", "2", time.Hour) {
+		t.Fatal("Add overwrote a live item")
+	}
+	if s.data["add"] != "one" {
+		t.Fatalf("a rejected Add saved %q", s.data["add"])
+	}
+}
+
+type failingStore struct{ memStore }
+
+func (s *failingStore) Save(key, value string, ttl time.Duration) error {
+	return errors.New("store down")
+}
+
+func TestWriteThroughSaveFailedLogged(t *testing.T) {
+	var events []string
+	c := New(WithWriteThrough(&failingStore{}), WithLogger(func(event string, fields map[string]any) {
+		events = append(events, event)
+	}))
+	if err := c.TrySet("a", "1", 0); err == nil {
+		t.Fatal("TrySet did not report the failed save")
+	}
+	c.Add("b", "2", 0)
+	if len(events) != 1 || events[0] != "save_failed" {
+		t.Fatalf("logged %v, want [save_failed]", events)
+	}
+	if !c.Has("b") {
+		t.Fatal("a failed save dropped the cached value")
+	}
+}
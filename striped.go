@@ -0,0 +1,207 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultStripes is the number of lock stripes NewStripedCache uses when
+// given zero.
+const defaultStripes = 256
+
+// StripedCache is a minimal cache for workloads dominated by distinct keys.
+// Keys are spread over many lock stripes, each a small map behind its own
+// lock, so reads and writes of different keys almost never contend. Unlike
+// ShardedCache, a stripe keeps no eviction list, expiry heap or statistics,
+// which is what makes it cheap enough to use hundreds of them; in exchange
+// there is no capacity limit or eviction policy. Expired items are hidden
+// from Get and removed by DeleteExpired, by the WithStripedJanitor janitor
+// or when overwritten.
+//
+// Every stripe is a separate map with its own growth slack, so a striped
+// cache uses somewhat more memory than a single map, in return for a lock
+// per stripe rather than one for the whole cache.
+type StripedCache struct {
+	stripes       []stripe
+	defaultExpiry time.Duration
+	clock         func() time.Time
+	readOnly      int32
+	stop          chan struct{}
+	stopOnce      sync.Once
+}
+
+type stripe struct {
+	mu    sync.RWMutex
+	items map[string]stripedItem
+}
+
+type stripedItem struct {
+	val    string
+	expiry int64 // UnixNano; 0 means the item never expires
+}
+
+// StripedOption configures a StripedCache at construction time.
+type StripedOption func(*stripedConfig)
+
+// stripedConfig holds the settings a StripedOption can change.
+type stripedConfig struct {
+	clock           func() time.Time
+	janitorInterval time.Duration
+}
+
+// WithStripedClock is WithClock for a StripedCache.
+func WithStripedClock(clock func() time.Time) StripedOption {
+	return func(cfg *stripedConfig) {
+		cfg.clock = clock
+	}
+}
+
+// WithStripedJanitor makes the striped cache call DeleteExpired every
+// interval until it is closed. Without it expired items stay in memory until
+// they are overwritten or DeleteExpired is called.
+func WithStripedJanitor(interval time.Duration) StripedOption {
+	return func(cfg *stripedConfig) {
+		cfg.janitorInterval = interval
+	}
+}
+
+// NewStripedCache creates a cache with the given number of lock stripes, or
+// defaultStripes if stripes is zero or negative. ed is the default expiry,
+// as for NewCache.
+func NewStripedCache(stripes int, ed time.Duration, opts ...StripedOption) *StripedCache {
+	cfg := stripedConfig{clock: time.Now}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if stripes < 1 {
+		stripes = defaultStripes
+	}
+	sc := &StripedCache{
+		stripes:       make([]stripe, stripes),
+		defaultExpiry: ed,
+		clock:         cfg.clock,
+		stop:          make(chan struct{}),
+	}
+	for i := range sc.stripes {
+		sc.stripes[i].items = make(map[string]stripedItem)
+	}
+	if cfg.janitorInterval > 0 {
+		go sc.janitor(cfg.janitorInterval)
+	}
+	return sc
+}
+
+// Set stores v under k. Like Cache.Set, DefaultExpiration uses the default
+// expiry and NoExpiration keeps the item forever. Set does nothing in
+// read-only mode.
+func (sc *StripedCache) Set(k, v string, expiry time.Duration) {
+	if atomic.LoadInt32(&sc.readOnly) != 0 {
+		return
+	}
+
+	if expiry == DefaultExpiration {
+		expiry = sc.defaultExpiry
+	}
+	it := stripedItem{val: v}
+	if expiry > 0 {
+		it.expiry = sc.clock().Add(expiry).UnixNano()
+	}
+
+	s := sc.stripe(k)
+	s.mu.Lock()
+	s.items[k] = it
+	s.mu.Unlock()
+}
+
+// Get returns the value stored under k, reporting an expired item as
+// missing.
+func (sc *StripedCache) Get(k string) (string, bool) {
+	s := sc.stripe(k)
+	s.mu.RLock()
+	it, ok := s.items[k]
+	s.mu.RUnlock()
+	if !ok || (it.expiry > 0 && sc.clock().UnixNano() > it.expiry) {
+		return "", false
+	}
+	return it.val, true
+}
+
+// Delete removes k. It does nothing in read-only mode.
+func (sc *StripedCache) Delete(k string) {
+	if atomic.LoadInt32(&sc.readOnly) != 0 {
+		return
+	}
+
+	s := sc.stripe(k)
+	s.mu.Lock()
+	delete(s.items, k)
+	s.mu.Unlock()
+}
+
+// Len returns the number of items that have not expired yet.
+func (sc *StripedCache) Len() int {
+	now := sc.clock().UnixNano()
+	n := 0
+	for i := range sc.stripes {
+		s := &sc.stripes[i]
+		s.mu.RLock()
+		for _, it := range s.items {
+			if it.expiry == 0 || now <= it.expiry {
+				n++
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// DeleteExpired removes all expired items, locking one stripe at a time.
+func (sc *StripedCache) DeleteExpired() {
+	now := sc.clock().UnixNano()
+	for i := range sc.stripes {
+		s := &sc.stripes[i]
+		s.mu.Lock()
+		for k, it := range s.items {
+			if it.expiry > 0 && now > it.expiry {
+				delete(s.items, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// SaveAndExit puts the cache in read-only mode, as Cache.SaveAndExit does.
+func (sc *StripedCache) SaveAndExit() {
+	atomic.StoreInt32(&sc.readOnly, 1)
+}
+
+// Resume leaves read-only mode.
+func (sc *StripedCache) Resume() {
+	atomic.StoreInt32(&sc.readOnly, 0)
+}
+
+// Close stops the janitor. It is safe to call Close more than once.
+func (sc *StripedCache) Close() error {
+	sc.stopOnce.Do(func() {
+		close(sc.stop)
+	})
+	return nil
+}
+
+func (sc *StripedCache) janitor(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-sc.stop:
+			return
+		case <-t.C:
+			sc.DeleteExpired()
+		}
+	}
+}
+
+func (sc *StripedCache) stripe(k string) *stripe {
+	return &sc.stripes[fnv64a(k)%uint64(len(sc.stripes))]
+}
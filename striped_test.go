@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStripedCache(t *testing.T) {
+	clock := newFakeClock()
+	sc := NewStripedCache(0, time.Minute, WithStripedClock(clock.Now))
+	if n := len(sc.stripes); n != defaultStripes {
+		t.Fatalf("stripes = %d, want %d", n, defaultStripes)
+	}
+
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		sc.Set(k, k, DefaultExpiration)
+	}
+	sc.Set("short", "x", time.Millisecond)
+	clock.Advance(5 * time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		if v, ok := sc.Get(k); !ok || v != k {
+			t.Fatalf("Get(%s) = %q, %v; want %q, true", k, v, ok, k)
+		}
+	}
+	if _, ok := sc.Get("short"); ok {
+		t.Fatal("Get returned an expired item")
+	}
+	if n := sc.Len(); n != 100 {
+		t.Fatalf("Len = %d, want 100", n)
+	}
+
+	sc.Delete("0")
+	sc.DeleteExpired()
+	total := 0
+	for i := range sc.stripes {
+		total += len(sc.stripes[i].items)
+	}
+	if total != 99 {
+		t.Fatalf("%d items stored after Delete and DeleteExpired, want 99", total)
+	}
+}
+
+func TestStripedCacheReadOnly(t *testing.T) {
+	sc := NewStripedCache(4, time.Minute)
+	sc.Set("a", "1", 0)
+	sc.SaveAndExit()
+	sc.Set("b", "2", 0)
+	sc.Delete("a")
+	if _, ok := sc.Get("b"); ok {
+		t.Fatal("Set stored a value in read-only mode")
+	}
+	if _, ok := sc.Get("a"); !ok {
+		t.Fatal("Delete removed a value in read-only mode")
+	}
+
+	sc.Resume()
+	sc.Set("b", "2", 0)
+	if _, ok := sc.Get("b"); !ok {
+		t.Fatal("Set after Resume was dropped")
+	}
+}
+
+func TestStripedCacheJanitor(t *testing.T) {
+	sc := NewStripedCache(4, time.Minute, WithStripedJanitor(time.Millisecond))
+	sc.Set("short", "x", time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		s := sc.stripe("short")
+		s.mu.RLock()
+		_, ok := s.items["short"]
+		s.mu.RUnlock()
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the janitor did not remove an expired item")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+func BenchmarkStripedCacheSetParallel(b *testing.B) {
+	benchmarkSetParallel(b, NewStripedCache(0, time.Minute).Set)
+}
+
+// The mixed benchmarks read and write a key space much larger than any
+// cache's lock count, so contention comes from the locking scheme alone.
+
+func BenchmarkCacheMixedParallel(b *testing.B) {
+	c := NewCacheWithJanitor(time.Minute, 0)
+	defer c.Close()
+	benchmarkMixedParallel(b, c.Set, c.Get)
+}
+
+func BenchmarkShardedCacheMixedParallel(b *testing.B) {
+	sc := NewShardedCache(32, time.Minute, 0)
+	defer sc.Close()
+	benchmarkMixedParallel(b, sc.Set, sc.Get)
+}
+
+func BenchmarkStripedCacheMixedParallel(b *testing.B) {
+	sc := NewStripedCache(0, time.Minute)
+	benchmarkMixedParallel(b, sc.Set, sc.Get)
+}
+
+func benchmarkMixedParallel(b *testing.B, set func(k, v string, expiry time.Duration), get func(k string) (string, bool)) {
+	var n int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddInt64(&n, 1)
+			k := strconv.FormatInt(i%(1<<20), 10)
+			if i%4 == 0 {
+				set(k, k, time.Hour)
+			} else {
+				get(k)
+			}
+		}
+	})
+}
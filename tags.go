@@ -0,0 +1,78 @@
+package main
+
+import "time"
+
+// SetWithTags stores v under k like Set and associates it with tags,
+// replacing any tags it had. A plain Set of an existing key keeps its tags.
+func (c *Cache) SetWithTags(k, v string, expiry time.Duration, tags ...string) {
+	if c.isReadOnly() || c.rateLimited() {
+		return
+	}
+
+	val, compressed, err := c.encode(v)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	if !c.setLocked(k, val, compressed, expiry) {
+		return
+	}
+	// The item may already have been evicted if it does not fit.
+	if it, ok := c.items[k]; ok {
+		c.setTagsLocked(it, tags)
+		c.saveLocked(k, v)
+	}
+}
+
+// InvalidateTag removes every item associated with tag and returns how many
+// were removed.
+func (c *Cache) InvalidateTag(tag string) int {
+	if c.isReadOnly() {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	keys := c.tags[tag]
+	n := len(keys)
+	for k := range keys {
+		c.removeLocked(k, ReasonDeleted)
+	}
+	return n
+}
+
+// setTagsLocked replaces the tags of it and updates the reverse index. The
+// caller must hold the write lock.
+func (c *Cache) setTagsLocked(it *item, tags []string) {
+	c.untagLocked(it)
+	it.tags = nil
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			if c.tags == nil {
+				c.tags = make(map[string]map[string]struct{})
+			}
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		if _, dup := keys[it.key]; dup {
+			continue
+		}
+		keys[it.key] = struct{}{}
+		it.tags = append(it.tags, tag)
+	}
+}
+
+// untagLocked removes it from the reverse index of every tag it has. The
+// caller must hold the write lock.
+func (c *Cache) untagLocked(it *item) {
+	for _, tag := range it.tags {
+		keys := c.tags[tag]
+		delete(keys, it.key)
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	c := NewCache(time.Minute)
+	c.SetWithTags("row1:a", "1", 0, "row1")
+	c.SetWithTags("row1:b", "2", 0, "row1", "hot")
+	c.SetWithTags("row2:a", "3", 0, "row2", "hot")
+	c.Set("plain", "4", 0)
+
+	if n := c.InvalidateTag("row1"); n != 2 {
+		t.Fatalf("InvalidateTag(row1) = %d, want 2", n)
+	}
+	for _, k := range []string{"row1:a", "row1:b"} {
+		if c.Has(k) {
+			t.Errorf("%s survived InvalidateTag(row1)", k)
+		}
+	}
+	for _, k := range []string{"row2:a", "plain"} {
+		if !c.Has(k) {
+			t.Errorf("%s was removed by InvalidateTag(row1)", k)
+		}
+	}
+
+	// row1:b was removed, so only row2:a is left under hot.
+	if n := c.InvalidateTag("hot"); n != 1 {
+		t.Fatalf("InvalidateTag(hot) = %d, want 1", n)
+	}
+	if n := c.InvalidateTag("row1"); n != 0 {
+		t.Fatalf("second InvalidateTag(row1) = %d, want 0", n)
+	}
+}
+
+func TestTagIndexFollowsRemoval(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	c.SetWithTags("deleted", "1", 0, "t")
+	c.SetWithTags("expired", "2", time.Second, "t")
+	c.SetWithTags("retagged", "3", 0, "t")
+
+	c.Delete("deleted")
+	clock.Advance(2 * time.Second)
+	c.DeleteExpired()
+	c.SetWithTags("retagged", "3", 0, "other")
+
+	if _, ok := c.tags["t"]; ok {
+		t.Fatalf("tag t still indexes %v", c.tags["t"])
+	}
+	if n := c.InvalidateTag("other"); n != 1 {
+		t.Fatalf("InvalidateTag(other) = %d, want 1", n)
+	}
+	if len(c.tags) != 0 {
+		t.Fatalf("tag index not empty: %v", c.tags)
+	}
+}
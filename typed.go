@@ -0,0 +1,180 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TypedCache stores values of any type without converting them to strings.
+// Cache keeps its string API, so the generic variant lives alongside it
+// rather than replacing it. Expired items are removed when read, by
+// DeleteExpired and by the WithTypedJanitor janitor.
+type TypedCache[V any] struct {
+	mu            *sync.RWMutex
+	items         map[string]*typedItem[V]
+	defaultExpiry time.Duration
+	clock         func() time.Time
+	readOnly      int32
+	stop          chan struct{}
+	stopOnce      sync.Once
+}
+
+type typedItem[V any] struct {
+	val    V
+	expiry int64 // UnixNano; 0 means never
+}
+
+// TypedOption configures a TypedCache at construction time.
+type TypedOption func(*typedConfig)
+
+// typedConfig holds the settings a TypedOption can change, so the options
+// need no type parameter.
+type typedConfig struct {
+	clock           func() time.Time
+	janitorInterval time.Duration
+}
+
+// WithTypedClock is WithClock for a TypedCache.
+func WithTypedClock(clock func() time.Time) TypedOption {
+	return func(cfg *typedConfig) {
+		cfg.clock = clock
+	}
+}
+
+// WithTypedJanitor makes the typed cache call DeleteExpired every interval
+// until it is closed. Without it an expired item that is never read stays in
+// memory until DeleteExpired is called.
+func WithTypedJanitor(interval time.Duration) TypedOption {
+	return func(cfg *typedConfig) {
+		cfg.janitorInterval = interval
+	}
+}
+
+// NewTypedCache creates a typed cache whose DefaultExpiration items expire
+// after ed; zero or a negative ed means they never expire.
+func NewTypedCache[V any](ed time.Duration, opts ...TypedOption) *TypedCache[V] {
+	cfg := typedConfig{clock: time.Now}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	c := &TypedCache[V]{
+		mu:            &sync.RWMutex{},
+		items:         make(map[string]*typedItem[V]),
+		defaultExpiry: ed,
+		clock:         cfg.clock,
+		stop:          make(chan struct{}),
+	}
+	if cfg.janitorInterval > 0 {
+		go c.janitor(cfg.janitorInterval)
+	}
+	return c
+}
+
+// Set stores v under k. Like Cache.Set, DefaultExpiration uses the default
+// expiry and NoExpiration keeps the item forever. Set does nothing in
+// read-only mode.
+func (c *TypedCache[V]) Set(k string, v V, expiry time.Duration) {
+	if atomic.LoadInt32(&c.readOnly) != 0 {
+		return
+	}
+
+	if expiry == DefaultExpiration {
+		expiry = c.defaultExpiry
+	}
+	it := &typedItem[V]{val: v}
+	if expiry > 0 {
+		it.expiry = c.clock().Add(expiry).UnixNano()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[k] = it
+}
+
+// Get returns the value stored under k. An expired item is removed from the
+// cache and reported as missing.
+func (c *TypedCache[V]) Get(k string) (V, bool) {
+	var zero V
+
+	c.mu.RLock()
+	v, ok := c.items[k]
+	c.mu.RUnlock()
+	if !ok {
+		return zero, false
+	}
+
+	if v.expired(c.clock().UnixNano()) {
+		c.mu.Lock()
+		if v, ok := c.items[k]; ok && v.expired(c.clock().UnixNano()) {
+			delete(c.items, k)
+		}
+		c.mu.Unlock()
+		return zero, false
+	}
+
+	return v.val, true
+}
+
+// GetOrDelete mirrors Cache.GetOrDelete.
+func (c *TypedCache[V]) GetOrDelete(k string) (V, bool) {
+	return c.Get(k)
+}
+
+// Delete removes k. It does nothing in read-only mode.
+func (c *TypedCache[V]) Delete(k string) {
+	if atomic.LoadInt32(&c.readOnly) != 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, k)
+}
+
+// DeleteExpired removes all expired items.
+func (c *TypedCache[V]) DeleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock().UnixNano()
+	for k, it := range c.items {
+		if it.expired(now) {
+			delete(c.items, k)
+		}
+	}
+}
+
+// SaveAndExit puts the cache in read-only mode, as Cache.SaveAndExit does.
+func (c *TypedCache[V]) SaveAndExit() {
+	atomic.StoreInt32(&c.readOnly, 1)
+}
+
+// Resume leaves read-only mode.
+func (c *TypedCache[V]) Resume() {
+	atomic.StoreInt32(&c.readOnly, 0)
+}
+
+// Close stops the janitor. It is safe to call Close more than once.
+func (c *TypedCache[V]) Close() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	return nil
+}
+
+func (c *TypedCache[V]) janitor(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			c.DeleteExpired()
+		}
+	}
+}
+
+func (it *typedItem[V]) expired(now int64) bool {
+	return it.expiry != 0 && now > it.expiry
+}
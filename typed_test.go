@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTypedCacheInt(t *testing.T) {
+	c := NewTypedCache[int](time.Minute)
+	c.Set("a", 42, time.Hour)
+
+	if v, ok := c.Get("a"); !ok || v != 42 {
+		t.Fatalf("Get = %d, %v; want 42, true", v, ok)
+	}
+}
+
+func TestTypedCacheBytes(t *testing.T) {
+	c := NewTypedCache[[]byte](time.Minute)
+	c.Set("a", []byte{0, 1, 2}, time.Hour)
+
+	if v, ok := c.GetOrDelete("a"); !ok || !bytes.Equal(v, []byte{0, 1, 2}) {
+		t.Fatalf("GetOrDelete = %v, %v; want [0 1 2], true", v, ok)
+	}
+}
+
+func TestTypedCacheStruct(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+	}
+	clock := newFakeClock()
+	c := NewTypedCache[user](time.Minute, WithTypedClock(clock.Now))
+	c.Set("a", user{Name: "bob", Age: 30}, time.Hour)
+	c.Set("expired", user{Name: "old"}, time.Millisecond)
+	clock.Advance(5 * time.Millisecond)
+
+	if v, ok := c.Get("a"); !ok || v != (user{Name: "bob", Age: 30}) {
+		t.Fatalf("Get = %+v, %v; want {bob 30}, true", v, ok)
+	}
+	if v, ok := c.Get("expired"); ok || v != (user{}) {
+		t.Fatalf("Get(expired) = %+v, %v; want zero value, false", v, ok)
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get found a deleted key")
+	}
+}
+
+func TestTypedCacheExpiry(t *testing.T) {
+	clock := newFakeClock()
+	c := NewTypedCache[int](time.Minute, WithTypedClock(clock.Now))
+	c.Set("default", 1, DefaultExpiration)
+	c.Set("forever", 2, NoExpiration)
+
+	clock.Advance(30 * time.Second)
+	if _, ok := c.Get("default"); !ok {
+		t.Fatal("a DefaultExpiration item expired before the default expiry")
+	}
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("default"); ok {
+		t.Fatal("a DefaultExpiration item outlived the default expiry")
+	}
+	if v, ok := c.Get("forever"); !ok || v != 2 {
+		t.Fatalf("Get(forever) = %d, %v; want 2, true", v, ok)
+	}
+}
+
+func TestTypedCacheReadOnly(t *testing.T) {
+	c := NewTypedCache[int](time.Minute)
+	c.Set("a", 1, time.Hour)
+	c.SaveAndExit()
+
+	c.Set("a", 2, time.Hour)
+	c.Delete("a")
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) in read-only mode = %d, %v; want 1, true", v, ok)
+	}
+
+	c.Resume()
+	c.Set("a", 3, time.Hour)
+	if v, _ := c.Get("a"); v != 3 {
+		t.Fatalf("Get(a) after Resume = %d, want 3", v)
+	}
+}
+
+func TestTypedCacheDeleteExpired(t *testing.T) {
+	clock := newFakeClock()
+	c := NewTypedCache[int](time.Minute, WithTypedClock(clock.Now))
+	c.Set("short", 1, time.Second)
+	c.Set("long", 2, time.Hour)
+	clock.Advance(2 * time.Second)
+
+	c.DeleteExpired()
+	c.mu.RLock()
+	_, short := c.items["short"]
+	_, long := c.items["long"]
+	c.mu.RUnlock()
+	if short || !long {
+		t.Fatalf("after DeleteExpired short stored = %v, long stored = %v; want false, true", short, long)
+	}
+}
+
+func TestTypedCacheJanitor(t *testing.T) {
+	c := NewTypedCache[int](time.Minute, WithTypedJanitor(time.Millisecond))
+	defer c.Close()
+	c.Set("short", 1, time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		c.mu.RLock()
+		_, ok := c.items["short"]
+		c.mu.RUnlock()
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the janitor did not remove an expired item")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import "time"
+
+// GetVersioned is like Get but also returns the version of the item. Every
+// write of a value gives the item a new version, greater than any version
+// given out before, so a changed version means the item was modified.
+func (c *Cache) GetVersioned(k string) (value string, version uint64, ok bool) {
+	e, err := c.getEncoded(k, c.lockForRead())
+	if err != nil {
+		return "", 0, false
+	}
+	s, err := c.decode(e.val, e.compressed)
+	if err != nil {
+		return "", 0, false
+	}
+	return s, e.version, true
+}
+
+// SetVersioned stores v under k only if the item's current version is
+// expectedVersion, as returned by GetVersioned; an expectedVersion of 0 means
+// k must hold no live item. It returns the item's version after the call and
+// whether v was stored.
+func (c *Cache) SetVersioned(k, v string, expiry time.Duration, expectedVersion uint64) (uint64, bool) {
+	if c.isReadOnly() || c.rateLimited() {
+		return 0, false
+	}
+
+	val, compressed, err := c.encode(v)
+	if err != nil {
+		return 0, false
+	}
+
+	c.mu.Lock()
+	defer c.unlock()
+	var current uint64
+	if it, err := c.liveLocked(k); err == nil {
+		current = it.version
+	}
+	if current != expectedVersion || !c.setLocked(k, val, compressed, expiry) {
+		return current, false
+	}
+	// The item may already have been evicted if it does not fit.
+	it, ok := c.items[k]
+	if !ok {
+		return current, false
+	}
+	c.saveLocked(k, v)
+	return it.version, true
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetVersioned(t *testing.T) {
+	c := NewCache(time.Minute)
+
+	v1, ok := c.SetVersioned("k", "a", 0, 0)
+	if !ok || v1 == 0 {
+		t.Fatalf("creating SetVersioned = %d, %v; want a version, true", v1, ok)
+	}
+	if _, ok := c.SetVersioned("k", "x", 0, 0); ok {
+		t.Fatal("SetVersioned with version 0 overwrote an existing item")
+	}
+
+	v2, ok := c.SetVersioned("k", "b", 0, v1)
+	if !ok || v2 <= v1 {
+		t.Fatalf("SetVersioned(b) = %d, %v; want a version above %d, true", v2, ok, v1)
+	}
+	if cur, ok := c.SetVersioned("k", "stale", 0, v1); ok || cur != v2 {
+		t.Fatalf("stale SetVersioned = %d, %v; want %d, false", cur, ok, v2)
+	}
+	if v, ver, ok := c.GetVersioned("k"); !ok || v != "b" || ver != v2 {
+		t.Fatalf("GetVersioned = %q, %d, %v; want \"b\", %d, true", v, ver, ok, v2)
+	}
+}
+
+func TestVersionsIncrease(t *testing.T) {
+	c := NewCache(time.Minute)
+	var last uint64
+	check := func(op string) {
+		t.Helper()
+		_, ver, ok := c.GetVersioned("k")
+		if !ok || ver <= last {
+			t.Fatalf("version after %s = %d, %v; want above %d", op, ver, ok, last)
+		}
+		last = ver
+	}
+
+	c.Set("k", "1", 0)
+	check("Set")
+	c.Set("k", "1", 0)
+	check("Set of the same value")
+	c.Increment("k", 1)
+	check("Increment")
+	c.Delete("k")
+	c.Set("k", "new", 0)
+	check("Delete and Set")
+	if _, ver, ok := c.GetVersioned("missing"); ok || ver != 0 {
+		t.Fatalf("GetVersioned(missing) = _, %d, %v; want 0, false", ver, ok)
+	}
+}
+
+func TestSetVersionedEvictedAtOnce(t *testing.T) {
+	c := New(WithMaxCost(10), WithEvictionPolicy(EvictLowestCost))
+	c.SetWithCost("a", "x", NoExpiration, 10)
+
+	// "b" costs 1, so it is the cheapest item and evicted as it is written.
+	if ver, ok := c.SetVersioned("b", "y", NoExpiration, 0); ok || ver != 0 {
+		t.Fatalf("SetVersioned of an evicted item = %d, %v; want 0, false", ver, ok)
+	}
+	if c.Has("b") {
+		t.Fatal("b is stored although it did not fit")
+	}
+}
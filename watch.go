@@ -0,0 +1,115 @@
+package main
+
+import "sync/atomic"
+
+// defaultWatchBuffer is the size of the channels returned by Watch when
+// WithWatchBuffer is not given.
+const defaultWatchBuffer = 64
+
+// EventType says what happened to a watched key.
+type EventType int
+
+const (
+	EventSet    EventType = iota // the key was stored or its value changed
+	EventDelete                  // the key was deleted or evicted
+	EventExpire                  // the key was removed because it expired
+)
+
+// Event is a change to a watched key. Value is the new value for EventSet
+// and the last value for EventDelete and EventExpire.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value string
+}
+
+// watchEvent is an event queued under the lock, sent once it is released.
+type watchEvent struct {
+	typ        EventType
+	key        string
+	val        []byte
+	compressed bool
+}
+
+// WithWatchBuffer sets the size of the channels returned by Watch. Events
+// for a watcher whose channel is full are dropped, so a slow watcher never
+// holds up the cache.
+func WithWatchBuffer(n int) Option {
+	return func(c *Cache) {
+		c.watchBuffer = n
+	}
+}
+
+// Watch returns a channel that receives an Event every time k is set,
+// deleted or expires. Events are dropped while the channel is full; see
+// WithWatchBuffer. Like the eviction callbacks, Flush and ReplaceAll send
+// no events. Release the channel with Unwatch.
+func (c *Cache) Watch(k string) <-chan Event {
+	n := c.watchBuffer
+	if n <= 0 {
+		n = defaultWatchBuffer
+	}
+	ch := make(chan Event, n)
+
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+	if c.watchers == nil {
+		c.watchers = make(map[string][]chan Event)
+	}
+	c.watchers[k] = append(c.watchers[k], ch)
+	atomic.AddInt32(&c.watching, 1)
+	return ch
+}
+
+// Unwatch stops the events Watch sends to ch for k and closes ch.
+func (c *Cache) Unwatch(k string, ch <-chan Event) {
+	c.watchMu.Lock()
+	defer c.watchMu.Unlock()
+	chans := c.watchers[k]
+	for i, w := range chans {
+		if w != ch {
+			continue
+		}
+		close(w)
+		chans = append(chans[:i], chans[i+1:]...)
+		if len(chans) == 0 {
+			delete(c.watchers, k)
+		} else {
+			c.watchers[k] = chans
+		}
+		atomic.AddInt32(&c.watching, -1)
+		return
+	}
+}
+
+// queueWatchLocked queues an event for it if any key is watched. The
+// caller must hold the write lock.
+func (c *Cache) queueWatchLocked(typ EventType, it *item) {
+	if atomic.LoadInt32(&c.watching) == 0 {
+		return
+	}
+	c.watchQueue = append(c.watchQueue, watchEvent{typ, it.key, it.val, it.compressed})
+}
+
+// sendWatchEvents delivers queued events to the watchers of their keys. It
+// must be called without the lock held.
+func (c *Cache) sendWatchEvents(events []watchEvent) {
+	c.watchMu.RLock()
+	defer c.watchMu.RUnlock()
+	for _, e := range events {
+		chans := c.watchers[e.key]
+		if len(chans) == 0 {
+			continue
+		}
+		v, err := c.decode(e.val, e.compressed)
+		if err != nil {
+			continue
+		}
+		for _, ch := range chans {
+			select {
+			case ch <- Event{Type: e.typ, Key: e.key, Value: v}:
+			default:
+			}
+		}
+	}
+}
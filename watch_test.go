@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	clock := newFakeClock()
+	c := New(WithClock(clock.Now))
+	ch := c.Watch("k")
+
+	c.Set("k", "v1", time.Hour)
+	c.Set("other", "x", time.Hour)
+	c.Set("k", "v2", time.Second)
+	c.Delete("k")
+	c.Set("k", "v3", time.Second)
+	clock.Advance(2 * time.Second)
+	c.DeleteExpired()
+
+	want := []Event{
+		{EventSet, "k", "v1"},
+		{EventSet, "k", "v2"},
+		{EventDelete, "k", "v2"},
+		{EventSet, "k", "v3"},
+		{EventExpire, "k", "v3"},
+	}
+	for _, w := range want {
+		select {
+		case e := <-ch:
+			if e != w {
+				t.Fatalf("event = %+v, want %+v", e, w)
+			}
+		default:
+			t.Fatalf("no event, want %+v", w)
+		}
+	}
+	select {
+	case e := <-ch:
+		t.Fatalf("unexpected event %+v", e)
+	default:
+	}
+
+	c.Unwatch("k", ch)
+	if _, open := <-ch; open {
+		t.Fatal("Unwatch did not close the channel")
+	}
+	c.Set("k", "v4", time.Hour)
+	if n := c.watching; n != 0 {
+		t.Fatalf("%d watchers left after Unwatch, want 0", n)
+	}
+}
+
+func TestWatchSlowWatcher(t *testing.T) {
+	c := New(WithWatchBuffer(1))
+	slow := c.Watch("k")
+	defer c.Unwatch("k", slow)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			c.Set("k", "v", time.Hour)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writes blocked on a watcher that is not reading")
+	}
+	if n := len(slow); n != 1 {
+		t.Fatalf("slow watcher has %d events buffered, want 1", n)
+	}
+}